@@ -0,0 +1,59 @@
+package osu
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+type AudioKind = int
+
+const (
+	// AUDIO_NONE means the map doesn't specify any audio
+	AUDIO_NONE = 0
+
+	// AUDIO_VIRTUAL means the map deliberately has no audio file, and is
+	// played in silence
+	AUDIO_VIRTUAL = 1
+
+	// AUDIO_FILE means the audio is in the file named by AudioFilename
+	AUDIO_FILE = 2
+)
+
+// the audio filename used by maps without any audio
+const VIRTUAL_AUDIO = "virtual"
+
+// the audio formats that the game can play
+var AUDIO_EXTENSIONS = []string{".mp3", ".ogg", ".wav"}
+
+// AudioKind returns what sort of audio the map uses.
+func (m *Beatmap) AudioKind() AudioKind {
+	switch {
+	case m.AudioFilename == "":
+		return AUDIO_NONE
+	case strings.EqualFold(m.AudioFilename, VIRTUAL_AUDIO):
+		return AUDIO_VIRTUAL
+	default:
+		return AUDIO_FILE
+	}
+}
+
+// ValidateAudio checks that the audio file, if there is one, has one of the
+// given extensions, or one of AUDIO_EXTENSIONS if none are given. This isn't
+// done when parsing, since the game will load maps with any audio filename.
+func (m *Beatmap) ValidateAudio(extensions ...string) error {
+	if m.AudioKind() != AUDIO_FILE {
+		return nil
+	}
+	if len(extensions) == 0 {
+		extensions = AUDIO_EXTENSIONS
+	}
+
+	ext := filepath.Ext(m.AudioFilename)
+	for _, allowed := range extensions {
+		if strings.EqualFold(ext, allowed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("AudioFilename '%s' doesn't have any of the extensions %v", m.AudioFilename, extensions)
+}
@@ -0,0 +1,40 @@
+package osu
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAudio(t *testing.T) {
+	tests := []struct {
+		filename string
+		kind     AudioKind
+		valid    bool
+	}{
+		{"audio.mp3", AUDIO_FILE, true},
+		{"Audio.OGG", AUDIO_FILE, true},
+		{"song.wav", AUDIO_FILE, true},
+		{"song.flac", AUDIO_FILE, false},
+		{"virtual", AUDIO_VIRTUAL, true},
+		{"", AUDIO_NONE, true},
+	}
+
+	for _, test := range tests {
+		m, err := ParseBeatmap(strings.NewReader("osu file format v14\n\n[General]\nAudioFilename: " + test.filename + "\n"))
+		if err != nil {
+			t.Errorf("failed to parse map with audio '%s': %v", test.filename, err)
+			continue
+		}
+		if m.AudioFilename != test.filename || m.AudioKind() != test.kind {
+			t.Errorf("wrong audio for '%s': '%s', kind %d", test.filename, m.AudioFilename, m.AudioKind())
+		}
+		if err := m.ValidateAudio(); (err == nil) != test.valid {
+			t.Errorf("wrong validation for '%s': %v", test.filename, err)
+		}
+	}
+
+	m := &Beatmap{AudioFilename: "song.flac"}
+	if err := m.ValidateAudio(".flac"); err != nil {
+		t.Errorf("expected custom extensions to be allowed: %v", err)
+	}
+}
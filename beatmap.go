@@ -5,9 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/oklog/ulid"
 )
 
 type Mode = int
@@ -19,14 +23,48 @@ const (
 	MODE_MANIA = 3
 )
 
+type CountdownSpeed = int
+
+const (
+	COUNTDOWN_NONE   = 0
+	COUNTDOWN_NORMAL = 1
+	COUNTDOWN_HALF   = 2
+	COUNTDOWN_DOUBLE = 3
+)
+
 var (
 	FILE_FORMAT_PATTERN = regexp.MustCompile(`^osu file format v(\d+)$`)
 	SECTION_PATTERN     = regexp.MustCompile(`^\[([[:alpha:]]+)\]$`)
-	KEY_VALUE_PATTERN   = regexp.MustCompile(`^([A-Za-z]+)\s*:\s*(.*)$`)
+	KEY_VALUE_PATTERN   = regexp.MustCompile(`^([A-Za-z0-9]+)\s*:\s*(.*)$`)
+	COMBO_COLOR_PATTERN = regexp.MustCompile(`^combo(\d+)$`)
 )
 
 var WHAT_THE_FUCK = map[bool]int{false: 0, true: 1}
 
+// the sections that are parsed, in lowercase
+var KNOWN_SECTIONS = map[string]bool{
+	"general":      true,
+	"editor":       true,
+	"metadata":     true,
+	"difficulty":   true,
+	"events":       true,
+	"timingpoints": true,
+	"colours":      true,
+	"hitobjects":   true,
+}
+
+// Field is a line from one of the key/value sections.
+type Field struct {
+	Key, Value string
+}
+
+// Section is a section of the file that isn't understood, with its lines kept
+// as they are.
+type Section struct {
+	Name  string
+	Lines []string
+}
+
 type Beatmap struct {
 	Version int
 
@@ -41,6 +79,12 @@ type Beatmap struct {
 	EpilepsyWarning      bool
 	WidescreenStoryboard bool
 
+	// CountdownSpeed is the speed of the countdown before the first object,
+	// which is ignored if it doesn't match Countdown
+	CountdownSpeed CountdownSpeed
+
+	Editor EditorSettings
+
 	Title          string
 	TitleUnicode   string
 	Artist         string
@@ -57,21 +101,59 @@ type Beatmap struct {
 	OverallDifficulty float64
 	ApproachRate      float64
 	SliderMultiplier  float64
-	SliderTickRate    int
+	SliderTickRate    float64
+
+	Events []Event
+
+	// Colors are the combo colours, in order, and ColorNumbers are the numbers
+	// they had in the file, so that Combo3 is written back out as Combo3. If
+	// ColorNumbers doesn't match up with Colors, they're numbered from 1. The
+	// slider colours are nil if the skin's colours should be used instead.
+	Colors              []Color
+	ColorNumbers        []int
+	SliderTrackOverride *Color
+	SliderBorder        *Color
+
+	// ExtraFields has the key/value lines that aren't otherwise understood,
+	// by the name of their section as it's written in the file, so that they
+	// can be written back out
+	ExtraFields map[string][]Field
+
+	// UnknownSections are kept so that they can be written back out, after
+	// all the others
+	UnknownSections []Section
 
-	Colors       []Color
 	TimingPoints []*TimingPoint
 	HitObjects   []*HitObject
 
-	// TODO: events
+	// hitObjectsByULID is built the first time it's needed, see edit.go
+	hitObjectsByULID map[ulid.ULID]*HitObject
 }
 
-func ParseBeatmap(reader io.Reader) (m *Beatmap, err error) {
+// ParseBeatmap parses a .osu file, stopping at the first problem. If the
+// problem is with the contents of the file rather than reading it, the error
+// is a *ParseError.
+func ParseBeatmap(reader io.Reader) (*Beatmap, error) {
+	m, _, err := parseBeatmap(reader, false)
+	return m, err
+}
+
+// ParseBeatmapLenient parses a .osu file, skipping over any lines it can't
+// understand and returning each problem as a warning. The error is only set
+// if the file couldn't be read.
+func ParseBeatmapLenient(reader io.Reader) (*Beatmap, []*ParseError, error) {
+	return parseBeatmap(reader, true)
+}
+
+func parseBeatmap(reader io.Reader, lenient bool) (m *Beatmap, warnings []*ParseError, err error) {
 	// Largely based on https://github.com/natsukagami/go-osu-parser/blob/master/parser.go
 	var section string
-	var buf []byte
 
-	m = &Beatmap{}
+	m = &Beatmap{Editor: DefaultEditorSettings()}
+
+	// the game's defaults, for maps that don't specify them
+	m.SliderMultiplier = 1.4
+	m.SliderTickRate = 1
 	bufreader := bufio.NewReader(reader)
 
 	// compatibility for older versions
@@ -79,16 +161,38 @@ func ParseBeatmap(reader io.Reader) (m *Beatmap, err error) {
 	artistUnicodeSet := false
 	titleUnicodeSet := false
 
+	// inherited timing points take their BPM and meter from the most recent
+	// uninherited point
+	var parentTimingPoint TimingPoint
+
+	// combo colours are numbered, so put them in order once we've seen them all
+	comboColors := make(map[int]Color)
+
 	m.BeatmapSetID = -1
 
-	for nLine := 0; err == nil; buf, _, err = bufreader.ReadLine() {
-		nLine += 1
+	// commands under an event that couldn't be parsed would otherwise end up
+	// on the event before it
+	skipCommands := false
+
+	for nLine := 1; ; nLine++ {
+		buf, readErr := bufreader.ReadString('\n')
+		if readErr == io.EOF && len(buf) == 0 {
+			break
+		} else if readErr != nil && readErr != io.EOF {
+			return nil, warnings, readErr
+		}
 
-		line := strings.Trim(string(buf), " \r\n")
+		raw := strings.TrimRight(buf, "\r\n")
+		raw = strings.TrimPrefix(raw, "\ufeff")
+		line := strings.Trim(raw, " ")
 		if len(line) == 0 {
 			// empty line
 			continue
 		}
+		if strings.HasPrefix(line, "//") {
+			// comment
+			continue
+		}
 
 		// check for osu file format header
 		if match := FILE_FORMAT_PATTERN.FindStringSubmatch(line); match != nil {
@@ -101,10 +205,21 @@ func ParseBeatmap(reader io.Reader) (m *Beatmap, err error) {
 		// update current section
 		if match := SECTION_PATTERN.FindStringSubmatch(line); match != nil {
 			section = match[1]
+			if !KNOWN_SECTIONS[strings.ToLower(section)] {
+				m.UnknownSections = append(m.UnknownSections, Section{Name: section})
+				warnings = append(warnings, &ParseError{
+					Line:    nLine,
+					Column:  strings.Index(raw, line) + 1,
+					Section: section,
+					Raw:     raw,
+					Err:     ErrUnknownSection,
+				})
+			}
 			continue
 		}
 
 		// yay all other sections
+		var lineErr error
 		switch strings.ToLower(section) {
 		case "general":
 			fallthrough
@@ -115,55 +230,57 @@ func ParseBeatmap(reader io.Reader) (m *Beatmap, err error) {
 		case "difficulty":
 			if match := KEY_VALUE_PATTERN.FindStringSubmatch(line); match != nil {
 				key, value := match[1], match[2]
+				var valueErr error
 				switch strings.ToLower(key) {
 				// [General]
 				case "audiofilename":
-					// check that its extension is mp3
-					if !strings.HasSuffix(strings.ToLower(value), ".mp3") {
-						return nil, errors.New("AudioFilename does not have the .mp3 extension")
-					}
-
 					m.AudioFilename = value
 				case "audioleadin":
-					if val, err := strconv.Atoi(value); err == nil {
-						m.AudioLeadIn = val
-					}
+					valueErr = parseIntValue(value, &m.AudioLeadIn)
 				case "previewtime":
-					if val, err := strconv.Atoi(value); err == nil {
-						m.PreviewTime = val
-					}
+					valueErr = parseIntValue(value, &m.PreviewTime)
 				case "countdown":
-					if val, err := strconv.Atoi(value); err == nil {
-						m.Countdown = val > 0
-					}
+					valueErr = parseIntValue(value, &m.CountdownSpeed)
+					m.Countdown = m.CountdownSpeed > COUNTDOWN_NONE
 				case "sampleset":
 					m.SampleSet = SAMPLE_SETS_INV[strings.ToLower(value)]
 				case "stackleniency":
-					if val, err := strconv.ParseFloat(value, 64); err == nil {
-						m.StackLeniency = val
-					}
+					valueErr = parseFloatValue(value, &m.StackLeniency)
+				case "mode":
+					valueErr = parseIntValue(value, &m.Mode)
 				case "letterboxinbreaks":
-					if val, err := strconv.Atoi(value); err == nil {
-						m.LetterboxInBreaks = val > 0
-					}
+					valueErr = parseBoolValue(value, &m.LetterboxInBreaks)
 				case "epilepsywarning":
-					if val, err := strconv.Atoi(value); err == nil {
-						m.EpilepsyWarning = val > 0
-					}
+					valueErr = parseBoolValue(value, &m.EpilepsyWarning)
 				case "widescreenstoryboard":
-					if val, err := strconv.Atoi(value); err == nil {
-						m.WidescreenStoryboard = val > 0
+					valueErr = parseBoolValue(value, &m.WidescreenStoryboard)
+
+				// [Editor]
+				case "bookmarks":
+					var bookmarks []int
+					if bookmarks, valueErr = ParseBookmarks(value); valueErr == nil {
+						m.Editor.Bookmarks = bookmarks
 					}
+				case "distancespacing":
+					valueErr = parseFloatValue(value, &m.Editor.DistanceSpacing)
+				case "beatdivisor":
+					valueErr = parseIntValue(value, &m.Editor.BeatDivisor)
+				case "gridsize":
+					valueErr = parseIntValue(value, &m.Editor.GridSize)
+				case "timelinezoom":
+					valueErr = parseFloatValue(value, &m.Editor.TimelineZoom)
 
 				// [Metadata]
 				case "title":
 					m.Title = value
 				case "titleunicode":
 					m.TitleUnicode = value
+					titleUnicodeSet = true
 				case "artist":
 					m.Artist = value
 				case "artistunicode":
 					m.ArtistUnicode = value
+					artistUnicodeSet = true
 				case "creator":
 					m.Creator = value
 				case "version":
@@ -171,61 +288,127 @@ func ParseBeatmap(reader io.Reader) (m *Beatmap, err error) {
 				case "source":
 					m.Source = value
 				case "tags":
-					m.Tags = strings.Split(value, " ")
-				case "beatmapid":
-					if val, err := strconv.Atoi(value); err == nil {
-						m.BeatmapID = val
+					if value != "" {
+						m.Tags = strings.Split(value, " ")
 					}
+				case "beatmapid":
+					valueErr = parseIntValue(value, &m.BeatmapID)
 				case "beatmapsetid":
-					if val, err := strconv.Atoi(value); err == nil {
-						m.BeatmapSetID = val
-					}
+					valueErr = parseIntValue(value, &m.BeatmapSetID)
 
 				// [Difficulty]
 				case "hpdrainrate":
-					if val, err := strconv.ParseFloat(value, 64); err == nil {
-						m.HPDrainRate = val
-					}
+					valueErr = parseFloatValue(value, &m.HPDrainRate)
 				case "circlesize":
-					if val, err := strconv.ParseFloat(value, 64); err == nil {
-						m.CircleSize = val
-					}
+					valueErr = parseFloatValue(value, &m.CircleSize)
 				case "overalldifficulty":
-					if val, err := strconv.ParseFloat(value, 64); err == nil {
-						m.OverallDifficulty = val
-					}
+					valueErr = parseFloatValue(value, &m.OverallDifficulty)
 				case "approachrate":
-					if val, err := strconv.ParseFloat(value, 64); err == nil {
-						m.ApproachRate = val
-					}
+					valueErr = parseFloatValue(value, &m.ApproachRate)
+					approachSet = valueErr == nil
+				case "slidermultiplier":
+					valueErr = parseFloatValue(value, &m.SliderMultiplier)
+				case "slidertickrate":
+					valueErr = parseFloatValue(value, &m.SliderTickRate)
 
 				default:
-					// return nil, fmt.Errorf("unknown key '%s'", key)
+					// keep keys we don't know about so they can be written back
+					m.addExtraField(section, key, value)
+				}
+				if valueErr != nil {
+					lineErr = fmt.Errorf("invalid %s: %w", key, valueErr)
 				}
 			} else {
-				return nil, fmt.Errorf("line %d\tfailed to match: '%+v'", nLine, line)
+				lineErr = ErrNoMatch
 			}
 		case "events":
-			// TODO:
+			// storyboard commands are indented underneath the object they apply to
+			if depth := commandDepth(raw); depth > 0 {
+				if skipCommands {
+					lineErr = ErrSkippedCommand
+					break
+				}
+				cmd, err := ParseStoryboardCommand(strings.TrimLeft(raw, " _"))
+				if err == nil {
+					err = addStoryboardCommand(m.Events, depth, cmd)
+				}
+				if err != nil {
+					lineErr = fmt.Errorf("invalid storyboard command: %w", err)
+				}
+			} else if ev, err := ParseEvent(line); err == nil {
+				m.Events = append(m.Events, ev)
+				skipCommands = false
+			} else {
+				lineErr = fmt.Errorf("invalid event: %w", err)
+				skipCommands = true
+			}
 		case "timingpoints":
-			// TODO:
+			if tp, err := ParseTimingPoint(line, parentTimingPoint); err == nil {
+				if _, ok := tp.(UninheritedTimingPoint); ok {
+					parentTimingPoint = tp
+				}
+				m.TimingPoints = append(m.TimingPoints, &tp)
+			} else {
+				lineErr = fmt.Errorf("invalid timing point: %w", err)
+			}
 		case "colours":
-			// TODO:
+			match := KEY_VALUE_PATTERN.FindStringSubmatch(line)
+			if match == nil {
+				lineErr = ErrNoMatch
+				break
+			}
+
+			key := strings.ToLower(match[1])
+			comboMatch := COMBO_COLOR_PATTERN.FindStringSubmatch(key)
+			if key != "slidertrackoverride" && key != "sliderborder" && comboMatch == nil {
+				m.addExtraField(section, match[1], match[2])
+				break
+			}
+
+			color, err := ParseColor(match[2])
+			if err != nil {
+				lineErr = fmt.Errorf("invalid colour: %w", err)
+				break
+			}
+
+			switch key {
+			case "slidertrackoverride":
+				m.SliderTrackOverride = &color
+			case "sliderborder":
+				m.SliderBorder = &color
+			default:
+				n, _ := strconv.Atoi(comboMatch[1])
+				comboColors[n] = color
+			}
 		case "hitobjects":
 			if obj, err := ParseHitObject(line); err == nil {
 				m.HitObjects = append(m.HitObjects, &obj)
 			} else {
-				return nil, fmt.Errorf("line %d\tinvalid hitobject: %s (line: '%s')", nLine, err, line)
+				lineErr = fmt.Errorf("invalid hitobject: %w", err)
 			}
 		default:
-			return nil, fmt.Errorf("line %d\tunknown section '%s'", nLine, section)
+			if len(m.UnknownSections) == 0 {
+				// there's no section header before this line at all
+				lineErr = ErrNoMatch
+				break
+			}
+			unknown := &m.UnknownSections[len(m.UnknownSections)-1]
+			unknown.Lines = append(unknown.Lines, raw)
 		}
-	}
 
-	if err == io.EOF {
-		// this is actually a success
-		// set err to nil so we can return success
-		err = nil
+		if lineErr != nil {
+			perr := &ParseError{
+				Line:    nLine,
+				Column:  strings.Index(raw, line) + 1,
+				Section: section,
+				Raw:     raw,
+				Err:     lineErr,
+			}
+			if !lenient {
+				return nil, nil, perr
+			}
+			warnings = append(warnings, perr)
+		}
 	}
 
 	// compatibility for older versions
@@ -240,14 +423,207 @@ func ParseBeatmap(reader io.Reader) (m *Beatmap, err error) {
 		m.TitleUnicode = m.Title
 	}
 
-	// return nil, fmt.Errorf("%#v", m)
+	var comboNumbers []int
+	for n := range comboColors {
+		comboNumbers = append(comboNumbers, n)
+	}
+	sort.Ints(comboNumbers)
+	for _, n := range comboNumbers {
+		m.Colors = append(m.Colors, comboColors[n])
+	}
+	m.ColorNumbers = comboNumbers
+
+	// inherited points that come before the first uninherited point still
+	// belong to it
+	if err = m.linkTimingPoints(lenient); err != nil {
+		perr := &ParseError{Section: "TimingPoints", Err: err}
+		if !lenient {
+			return nil, nil, perr
+		}
+		warnings = append(warnings, perr)
+	}
+
+	return m, warnings, nil
+}
+
+// Background returns the background image event, or nil if there isn't one.
+func (m *Beatmap) Background() *Background {
+	for _, ev := range m.Events {
+		if bg, ok := ev.(*Background); ok {
+			return bg
+		}
+	}
+	return nil
+}
+
+// Breaks returns all of the break periods, in the order they appear.
+func (m *Beatmap) Breaks() (breaks []*Break) {
+	for _, ev := range m.Events {
+		if brk, ok := ev.(*Break); ok {
+			breaks = append(breaks, brk)
+		}
+	}
+	return
+}
+
+// parseIntValue, parseFloatValue and parseBoolValue parse the value of a
+// key/value line into dst, leaving it alone if the value is invalid.
+func parseIntValue(value string, dst *int) error {
+	val, err := strconv.Atoi(strings.TrimSpace(value))
+	if err == nil {
+		*dst = val
+	}
+	return err
+}
+
+func parseFloatValue(value string, dst *float64) error {
+	val, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err == nil {
+		*dst = val
+	}
+	return err
+}
+
+func parseBoolValue(value string, dst *bool) error {
+	val, err := strconv.Atoi(strings.TrimSpace(value))
+	if err == nil {
+		*dst = val > 0
+	}
+	return err
+}
+
+func (m *Beatmap) addExtraField(section, key, value string) {
+	if m.ExtraFields == nil {
+		m.ExtraFields = make(map[string][]Field)
+	}
+	m.ExtraFields[section] = append(m.ExtraFields[section], Field{key, value})
+}
+
+func (m *Beatmap) writeExtraFields(writer io.Writer, section, separator string) {
+	// the section might not have been capitalized the usual way in the file
+	var names []string
+	for name := range m.ExtraFields {
+		if strings.EqualFold(name, section) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, field := range m.ExtraFields[name] {
+			fmt.Fprintf(writer, "%s%s%s\n", field.Key, separator, field.Value)
+		}
+	}
+}
+
+// KeyCount returns the number of columns in an osu!mania map, which is stored
+// as the circle size. Maps with more than MANIA_MAX_STAGE_KEYS keys are split
+// evenly between two stages, so an odd key count loses a column.
+func (m *Beatmap) KeyCount() (keys int) {
+	for _, stageKeys := range m.ManiaStages() {
+		keys += stageKeys
+	}
+	return
+}
+
+// ManiaStages returns the number of keys in each stage of an osu!mania map.
+func (m *Beatmap) ManiaStages() []int {
+	keys := int(math.Max(1, math.Round(m.CircleSize)))
+	if keys > MANIA_MAX_STAGE_KEYS {
+		return []int{keys / 2, keys / 2}
+	}
+	return []int{keys}
+}
+
+// ManiaStageColumn returns which stage a column is in, and where it is in that
+// stage.
+func (m *Beatmap) ManiaStageColumn(column int) (stage int, stageColumn int) {
+	stages := m.ManiaStages()
+	for i, keys := range stages {
+		if column < keys || i == len(stages)-1 {
+			return i, column
+		}
+		column -= keys
+	}
+	return 0, column
+}
+
+// TimingPointAt returns the timing point in effect at the given time, which is
+// the last one that starts at or before it. Anything before the first timing
+// point uses the first one. It returns nil if there aren't any timing points.
+func (m *Beatmap) TimingPointAt(time int) TimingPoint {
+	if len(m.TimingPoints) == 0 {
+		return nil
+	}
+
+	// timing points are in order, but there can be more than one at the same
+	// time, in which case the later one wins
+	i := sort.Search(len(m.TimingPoints), func(i int) bool {
+		return (*m.TimingPoints[i]).GetTimestamp().Milliseconds() > time
+	})
+	if i == 0 {
+		return *m.TimingPoints[0]
+	}
+	return *m.TimingPoints[i-1]
+}
+
+// linkTimingPoints gives every inherited timing point the closest uninherited
+// point before it as its parent. Inherited points that come before all of the
+// uninherited ones get the first one. If there aren't any uninherited points
+// to use, it fails, or if drop is set, removes the orphans instead.
+func (m *Beatmap) linkTimingPoints(drop bool) error {
+	var first TimingPoint
+	for _, tp := range m.TimingPoints {
+		if _, ok := (*tp).(UninheritedTimingPoint); ok {
+			first = *tp
+			break
+		}
+	}
+
+	if first == nil {
+		if orphanedTimingPoints(m.TimingPoints) == 0 {
+			return nil
+		}
+		if drop {
+			m.TimingPoints = nil
+		}
+		return errors.New("inherited timing point without any uninherited timing points")
+	}
+
+	parent := first
+	for _, tp := range m.TimingPoints {
+		switch p := (*tp).(type) {
+		case UninheritedTimingPoint:
+			parent = p
+		case InheritedTimingPoint:
+			p.Parent = parent
+			*tp = p
+		}
+	}
+
+	return nil
+}
+
+// orphanedTimingPoints counts the inherited timing points that don't have an
+// uninherited point before them.
+func orphanedTimingPoints(timingPoints []*TimingPoint) (n int) {
+	for _, tp := range timingPoints {
+		if _, ok := (*tp).(UninheritedTimingPoint); ok {
+			break
+		}
+		n++
+	}
 	return
 }
 
 // Serialize renders the beatmap into
-func (m *Beatmap) Serialize(writer io.Writer) (err error) {
+func (m *Beatmap) Serialize(w io.Writer) (err error) {
 	var line string
 
+	// writes are buffered, and the first error writing them out comes back
+	// when it's flushed at the end
+	writer := bufio.NewWriter(w)
+
 	fmt.Fprintf(writer, "osu file format v%d\n", m.Version)
 	fmt.Fprintf(writer, "\n")
 
@@ -255,13 +631,29 @@ func (m *Beatmap) Serialize(writer io.Writer) (err error) {
 	fmt.Fprintf(writer, "AudioFilename: %s\n", m.AudioFilename)
 	fmt.Fprintf(writer, "AudioLeadIn: %d\n", m.AudioLeadIn)
 	fmt.Fprintf(writer, "PreviewTime: %d\n", m.PreviewTime)
-	fmt.Fprintf(writer, "Countdown: %d\n", WHAT_THE_FUCK[m.Countdown])
+	countdown := m.CountdownSpeed
+	if m.Countdown != (countdown > COUNTDOWN_NONE) {
+		countdown = WHAT_THE_FUCK[m.Countdown]
+	}
+	fmt.Fprintf(writer, "Countdown: %d\n", countdown)
 	fmt.Fprintf(writer, "SampleSet: %s\n", SAMPLE_SETS[m.SampleSet])
-	fmt.Fprintf(writer, "StackLeniency: %f\n", m.StackLeniency)
+	fmt.Fprintf(writer, "StackLeniency: %s\n", strconv.FormatFloat(m.StackLeniency, 'f', -1, 64))
 	fmt.Fprintf(writer, "Mode: %d\n", m.Mode)
 	fmt.Fprintf(writer, "LetterboxInBreaks: %d\n", WHAT_THE_FUCK[m.LetterboxInBreaks])
 	fmt.Fprintf(writer, "EpilepsyWarning: %d\n", WHAT_THE_FUCK[m.EpilepsyWarning])
 	fmt.Fprintf(writer, "WidescreenStoryboard: %d\n", WHAT_THE_FUCK[m.WidescreenStoryboard])
+	m.writeExtraFields(writer, "General", ": ")
+	fmt.Fprintf(writer, "\n")
+
+	fmt.Fprintf(writer, "[Editor]\n")
+	if len(m.Editor.Bookmarks) > 0 {
+		fmt.Fprintf(writer, "Bookmarks: %s\n", m.Editor.BookmarksString())
+	}
+	fmt.Fprintf(writer, "DistanceSpacing: %s\n", strconv.FormatFloat(m.Editor.DistanceSpacing, 'f', -1, 64))
+	fmt.Fprintf(writer, "BeatDivisor: %d\n", m.Editor.BeatDivisor)
+	fmt.Fprintf(writer, "GridSize: %d\n", m.Editor.GridSize)
+	fmt.Fprintf(writer, "TimelineZoom: %s\n", strconv.FormatFloat(m.Editor.TimelineZoom, 'f', -1, 64))
+	m.writeExtraFields(writer, "Editor", ": ")
 	fmt.Fprintf(writer, "\n")
 
 	fmt.Fprintf(writer, "[Metadata]\n")
@@ -275,27 +667,60 @@ func (m *Beatmap) Serialize(writer io.Writer) (err error) {
 	fmt.Fprintf(writer, "Tags:%s\n", strings.Join(m.Tags, " "))
 	fmt.Fprintf(writer, "BeatmapID:%d\n", m.BeatmapID)
 	fmt.Fprintf(writer, "BeatmapSetID:%d\n", m.BeatmapSetID)
+	m.writeExtraFields(writer, "Metadata", ":")
 	fmt.Fprintf(writer, "\n")
 
 	fmt.Fprintf(writer, "[Difficulty]\n")
-	fmt.Fprintf(writer, "HPDrainRate:%.01f\n", m.HPDrainRate)
-	fmt.Fprintf(writer, "CircleSize:%.01f\n", m.CircleSize)
-	fmt.Fprintf(writer, "OverallDifficulty:%.01f\n", m.OverallDifficulty)
-	fmt.Fprintf(writer, "ApproachRate:%.01f\n", m.ApproachRate)
+	fmt.Fprintf(writer, "HPDrainRate:%s\n", strconv.FormatFloat(m.HPDrainRate, 'f', -1, 64))
+	fmt.Fprintf(writer, "CircleSize:%s\n", strconv.FormatFloat(m.CircleSize, 'f', -1, 64))
+	fmt.Fprintf(writer, "OverallDifficulty:%s\n", strconv.FormatFloat(m.OverallDifficulty, 'f', -1, 64))
+	fmt.Fprintf(writer, "ApproachRate:%s\n", strconv.FormatFloat(m.ApproachRate, 'f', -1, 64))
+	fmt.Fprintf(writer, "SliderMultiplier:%s\n", strconv.FormatFloat(m.SliderMultiplier, 'f', -1, 64))
+	fmt.Fprintf(writer, "SliderTickRate:%s\n", strconv.FormatFloat(m.SliderTickRate, 'f', -1, 64))
+	m.writeExtraFields(writer, "Difficulty", ":")
 	fmt.Fprintf(writer, "\n")
 
 	fmt.Fprintf(writer, "[Events]\n")
+	for _, ev := range m.Events {
+		line, err = ev.Serialize()
+		if err != nil {
+			return
+		}
+
+		fmt.Fprintf(writer, "%s\n", line)
+	}
 	fmt.Fprintf(writer, "\n")
 
 	fmt.Fprintf(writer, "[TimingPoints]\n")
+	for _, tp := range m.TimingPoints {
+		line, err = (*tp).Serialize()
+		if err != nil {
+			return
+		}
+
+		fmt.Fprintf(writer, "%s\n", line)
+	}
 	fmt.Fprintf(writer, "\n")
 
 	fmt.Fprintf(writer, "[Colours]\n")
+	for i, color := range m.Colors {
+		n := i + 1
+		if len(m.ColorNumbers) == len(m.Colors) {
+			n = m.ColorNumbers[i]
+		}
+		fmt.Fprintf(writer, "Combo%d : %s\n", n, color)
+	}
+	if m.SliderTrackOverride != nil {
+		fmt.Fprintf(writer, "SliderTrackOverride : %s\n", m.SliderTrackOverride)
+	}
+	if m.SliderBorder != nil {
+		fmt.Fprintf(writer, "SliderBorder : %s\n", m.SliderBorder)
+	}
+	m.writeExtraFields(writer, "Colours", " : ")
 	fmt.Fprintf(writer, "\n")
 
 	fmt.Fprintf(writer, "[HitObjects]\n")
 	for _, obj := range m.HitObjects {
-		// TODO: handle err
 		line, err = (*obj).Serialize()
 		if err != nil {
 			return
@@ -305,5 +730,13 @@ func (m *Beatmap) Serialize(writer io.Writer) (err error) {
 	}
 	fmt.Fprintf(writer, "\n")
 
-	return
+	for _, unknown := range m.UnknownSections {
+		fmt.Fprintf(writer, "[%s]\n", unknown.Name)
+		for _, line := range unknown.Lines {
+			fmt.Fprintf(writer, "%s\n", line)
+		}
+		fmt.Fprintf(writer, "\n")
+	}
+
+	return writer.Flush()
 }
@@ -2,37 +2,240 @@ package osu
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"math/rand"
-	"os"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+
+	"github.com/oklog/ulid"
 )
 
 func testSingle(filename string) func(*testing.T) {
 	return func(t *testing.T) {
-		f, err := os.Open("./test/" + filename)
+		source, err := ioutil.ReadFile("./test/" + filename)
 		if err != nil {
-			t.Errorf("failed to locate file '%s'", filename)
+			t.Fatalf("failed to locate file '%s'", filename)
 		}
 
-		beatmap, err := ParseBeatmap(f)
+		beatmap, err := ParseBeatmap(bytes.NewReader(source))
 		if err != nil {
-			t.Errorf("failed to parse file '%s': %+v", filename, err)
-			return
+			t.Fatalf("failed to parse file '%s': %+v", filename, err)
 		}
 
 		var buf bytes.Buffer
 		err = beatmap.Serialize(&buf)
 		if err != nil {
-			t.Errorf("failed to serialize: %+v", err)
-			return
+			t.Fatalf("failed to serialize: %+v", err)
+		}
+		serialized := buf.String()
+		compareSource(t, string(source), serialized)
+
+		beatmap2, err := ParseBeatmap(&buf)
+		if err != nil {
+			t.Fatalf("failed to parse serialized output: %+v", err)
+		}
+		compareBeatmaps(t, beatmap, beatmap2)
+
+		// serializing the reparsed map should give exactly the same output
+		buf.Reset()
+		err = beatmap2.Serialize(&buf)
+		if err != nil {
+			t.Fatalf("failed to serialize reparsed beatmap: %+v", err)
+		}
+		if reserialized := buf.String(); reserialized != serialized {
+			lines1 := strings.Split(serialized, "\n")
+			lines2 := strings.Split(reserialized, "\n")
+			for i := 0; i < len(lines1) && i < len(lines2); i++ {
+				if lines1[i] != lines2[i] {
+					t.Errorf("line %d differs after reserializing: '%s' != '%s'", i+1, lines1[i], lines2[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+// compareSource checks that everything in the original file made it into the
+// serialized output. The output can have extra keys filled in with defaults,
+// lines can have extra trailing fields and empty fields can be filled in, but
+// everything else must match, allowing for differences in how numbers are
+// written.
+func compareSource(t *testing.T, source, serialized string) {
+	sourceSections, serializedSections := splitSections(source), splitSections(serialized)
+	for section, lines := range sourceSections {
+		output := serializedSections[section]
+
+		switch section {
+		case "general", "editor", "metadata", "difficulty", "colours":
+			values := make(map[string]string)
+			for _, line := range output {
+				if match := KEY_VALUE_PATTERN.FindStringSubmatch(line); match != nil {
+					values[strings.ToLower(match[1])] = strings.TrimSpace(match[2])
+				}
+			}
+			for _, line := range lines {
+				match := KEY_VALUE_PATTERN.FindStringSubmatch(line)
+				if match == nil {
+					t.Errorf("[%s] invalid line '%s'", section, line)
+					continue
+				}
+				value, ok := values[strings.ToLower(match[1])]
+				if !ok {
+					t.Errorf("[%s] missing '%s'", section, line)
+				} else if !fieldsEqual(strings.TrimSpace(match[2]), value) {
+					t.Errorf("[%s] '%s' was written as '%s'", section, line, value)
+				}
+			}
+		default:
+			if len(lines) != len(output) {
+				t.Errorf("[%s] expected %d lines, got %d", section, len(lines), len(output))
+				continue
+			}
+			for i, line := range lines {
+				if !lineContains(line, output[i], ",", section == "events") {
+					t.Errorf("[%s] '%s' was written as '%s'", section, line, output[i])
+				}
+			}
+		}
+	}
+}
+
+// lineContains checks that every field of the source line is in the output
+// line, recursing into fields split by colons, like hitsamples.
+func lineContains(source, output, sep string, events bool) bool {
+	fields, outputFields := strings.Split(source, sep), strings.Split(output, sep)
+	if len(outputFields) < len(fields) {
+		return false
+	}
+	for i, field := range fields {
+		switch {
+		case field == "" || fieldsEqual(field, outputFields[i]):
+		case events && eventNameOf(field, outputFields[i]):
+		case sep == "," && strings.Contains(field, ":"):
+			if !lineContains(field, outputFields[i], ":", events) {
+				return false
+			}
+		default:
+			return false
 		}
+	}
+	return true
+}
 
-		t.Errorf("unserialized: %+v", buf.String())
+// eventNameOf checks whether name is what a number in the [Events] section is
+// written as, whether it's the type of event, a layer, an origin or a loop
+// type.
+func eventNameOf(number, name string) bool {
+	n, err := strconv.Atoi(strings.TrimSpace(number))
+	if err != nil {
+		return false
 	}
+	eventTypes := []string{"Background", "Video", "Break", "Colour", "Sprite", "Sample", "Animation"}
+	for _, names := range []map[int]string{STORYBOARD_LAYERS, STORYBOARD_ORIGINS, ANIMATION_LOOP_TYPES} {
+		if names[n] == strings.TrimSpace(name) {
+			return true
+		}
+	}
+	return n < len(eventTypes) && eventTypes[n] == name
+}
+
+// splitSections returns the lines in each section of a file, by the section's
+// name in lowercase, without blank lines or comments.
+func splitSections(file string) map[string][]string {
+	sections := make(map[string][]string)
+	section := ""
+	for _, line := range strings.Split(file, "\n") {
+		line = strings.TrimRight(strings.TrimPrefix(line, "\ufeff"), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "//") || FILE_FORMAT_PATTERN.MatchString(line) {
+			continue
+		}
+		if match := SECTION_PATTERN.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			section = strings.ToLower(match[1])
+			continue
+		}
+		sections[section] = append(sections[section], line)
+	}
+	return sections
+}
+
+// fieldsEqual compares two values from a file, treating numbers that are
+// written differently as the same.
+func fieldsEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+	x, err1 := strconv.ParseFloat(a, 64)
+	y, err2 := strconv.ParseFloat(b, 64)
+	return err1 == nil && err2 == nil && x == y
+}
+
+func compareBeatmaps(t *testing.T, m1, m2 *Beatmap) {
+	// compare everything except the lists we need to look into more closely
+	c1, c2 := *m1, *m2
+	c1.TimingPoints, c2.TimingPoints = nil, nil
+	c1.HitObjects, c2.HitObjects = nil, nil
+	if !reflect.DeepEqual(c1, c2) {
+		t.Errorf("beatmaps differ:\n%+v\n%+v", c1, c2)
+	}
+
+	if len(m1.TimingPoints) != len(m2.TimingPoints) {
+		t.Errorf("expected %d timing points, got %d", len(m1.TimingPoints), len(m2.TimingPoints))
+	} else {
+		for i := range m1.TimingPoints {
+			if !timingPointsEqual(*m1.TimingPoints[i], *m2.TimingPoints[i]) {
+				t.Errorf("timing point %d differs: %+v != %+v", i, *m1.TimingPoints[i], *m2.TimingPoints[i])
+			}
+		}
+	}
+
+	if len(m1.HitObjects) != len(m2.HitObjects) {
+		t.Errorf("expected %d hit objects, got %d", len(m1.HitObjects), len(m2.HitObjects))
+	} else {
+		for i := range m1.HitObjects {
+			obj1, obj2 := withoutULID(*m1.HitObjects[i]), withoutULID(*m2.HitObjects[i])
+			if !reflect.DeepEqual(obj1, obj2) {
+				t.Errorf("hit object %d differs: %+v != %+v", i, obj1, obj2)
+			}
+		}
+	}
+}
+
+// timingPointsEqual compares timing points, including their parents.
+func timingPointsEqual(tp1, tp2 TimingPoint) bool {
+	if p1, ok := tp1.(InheritedTimingPoint); ok {
+		p2, ok := tp2.(InheritedTimingPoint)
+		if !ok || !timingPointsEqual(p1.Parent, p2.Parent) {
+			return false
+		}
+		p1.Parent, p2.Parent = nil, nil
+		return p1 == p2
+	}
+	return tp1 == tp2
+}
+
+// withoutULID blanks out the ULID, which is regenerated every time an object
+// is parsed.
+func withoutULID(obj HitObject) HitObject {
+	switch o := obj.(type) {
+	case ObjCircle:
+		o.ulid = ulid.ULID{}
+		return o
+	case ObjSlider:
+		o.ulid = ulid.ULID{}
+		return o
+	case ObjSpinner:
+		o.ulid = ulid.ULID{}
+		return o
+	case ObjHoldNote:
+		o.ulid = ulid.ULID{}
+		return o
+	}
+	return obj
 }
 
 func TestSerialization(t *testing.T) {
@@ -58,3 +261,50 @@ func TestSerialization(t *testing.T) {
 		t.Run(fmt.Sprintf("test%d", i), testSingle(file.Name()))
 	}
 }
+
+// failingWriter fails every write after the first n bytes.
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errors.New("disk full")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestSerializeErrors(t *testing.T) {
+	m, err := ParseBeatmap(strings.NewReader("osu file format v14\n\n[General]\nCountdown: 2\n"))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if !m.Countdown || m.CountdownSpeed != COUNTDOWN_HALF {
+		t.Errorf("wrong countdown: %v, %d", m.Countdown, m.CountdownSpeed)
+	}
+
+	var buf bytes.Buffer
+	if err = m.Serialize(&buf); err != nil {
+		t.Fatalf("failed to serialize: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Countdown: 2\n") {
+		t.Errorf("countdown speed wasn't kept:\n%s", buf.String())
+	}
+
+	// turning off the countdown overrides its speed
+	m.Countdown = false
+	buf.Reset()
+	if err = m.Serialize(&buf); err != nil {
+		t.Fatalf("failed to serialize: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Countdown: 0\n") {
+		t.Errorf("countdown wasn't turned off:\n%s", buf.String())
+	}
+
+	if err = m.Serialize(&failingWriter{n: 100}); err == nil {
+		t.Error("expected an error from the writer")
+	}
+}
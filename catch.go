@@ -0,0 +1,293 @@
+package osu
+
+import (
+	"math"
+	"sort"
+)
+
+type CatchObjectType = int
+
+const (
+	CATCH_FRUIT        = 0
+	CATCH_DROPLET      = 1
+	CATCH_TINY_DROPLET = 2
+	CATCH_BANANA       = 3
+)
+
+const (
+	CATCH_PLAYFIELD_WIDTH = 512.0
+
+	// the seed the game uses for the random positions of bananas and such
+	CATCH_RNG_SEED = 1337
+
+	// the width of the catcher at circle size 5, and the fraction of it that
+	// can actually catch things
+	CATCHER_BASE_SIZE           = 106.75
+	CATCHER_ALLOWED_CATCH_RANGE = 0.8
+)
+
+// CatchObject is something that falls from the top of the screen in
+// osu!catch.
+type CatchObject struct {
+	Type      CatchObjectType
+	StartTime float64
+	X         float64
+
+	// HyperDash is set if the catcher can't walk or dash to the next object
+	// in time, so it gets a boost. Otherwise, DistanceToHyperDash is how much
+	// further the next object could be without needing one.
+	HyperDash           bool
+	DistanceToHyperDash float64
+}
+
+// CatcherWidth returns the width of the catcher for the given circle size.
+func CatcherWidth(circleSize float64) float64 {
+	scale := 1 - 0.7*(circleSize-5)/5
+	return CATCHER_BASE_SIZE * math.Abs(scale) * CATCHER_ALLOWED_CATCH_RANGE
+}
+
+// CatchObjects converts the hit objects into osu!catch objects, in order of
+// start time. circleSize decides the size of the catcher, and hardRock adds
+// the extra randomness that comes with the Hard Rock mod.
+func (m *Beatmap) CatchObjects(circleSize float64, hardRock bool) []CatchObject {
+	rng := newLegacyRandom(CATCH_RNG_SEED)
+
+	var objects []CatchObject
+	var lastPosition *float64
+	lastStartTime := 0.0
+
+	for _, hitObject := range m.HitObjects {
+		startTime := float64((*hitObject).GetStartTime().Milliseconds())
+
+		switch o := (*hitObject).(type) {
+		case ObjSlider:
+			stream := m.juiceStream(o)
+			for i := range stream {
+				if stream[i].Type == CATCH_TINY_DROPLET {
+					offset := float64(rng.nextRange(-20, 20))
+					stream[i].X += math.Max(-stream[i].X, math.Min(CATCH_PLAYFIELD_WIDTH-stream[i].X, offset))
+				} else if stream[i].Type == CATCH_DROPLET {
+					// the game used to pick a random rotation for droplets
+					rng.next()
+				}
+			}
+			objects = append(objects, stream...)
+
+			// the game used the last control point and the start time here,
+			// rather than where and when the slider actually ends
+			ctlPoints := o.GetControlPoints()
+			end := float64(ctlPoints[len(ctlPoints)-1].x)
+			lastPosition = &end
+			lastStartTime = startTime
+		case ObjSpinner:
+			duration := float64(o.endTime.Milliseconds()) - startTime
+			spacing := duration
+			for spacing > 100 {
+				spacing /= 2
+			}
+			if spacing <= 0 {
+				continue
+			}
+
+			for t := startTime; t <= startTime+duration; t += spacing {
+				objects = append(objects, CatchObject{Type: CATCH_BANANA, StartTime: t, X: rng.nextDouble() * CATCH_PLAYFIELD_WIDTH})
+
+				// the game used to pick a random type, rotation and colour
+				rng.next()
+				rng.next()
+				rng.next()
+			}
+		case ObjHoldNote:
+			continue
+		default:
+			fruit := CatchObject{Type: CATCH_FRUIT, StartTime: startTime, X: float64((*hitObject).GetPosition().x)}
+			if hardRock {
+				applyHardRockOffset(&fruit, &lastPosition, &lastStartTime, rng)
+			}
+			objects = append(objects, fruit)
+		}
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool { return objects[i].StartTime < objects[j].StartTime })
+	initialiseHyperDash(objects, CatcherWidth(circleSize)/2)
+	return objects
+}
+
+// juiceStream turns a slider into fruits at the head, repeats and tail, with
+// droplets for the ticks and tiny droplets in between.
+func (m *Beatmap) juiceStream(obj ObjSlider) (stream []CatchObject) {
+	var last *SliderEvent
+	for _, ev := range obj.Events(m.SliderTiming(obj)) {
+		if last != nil {
+			// the game works with whole milliseconds here
+			sinceLastTick := float64(int(ev.Time) - int(last.Time))
+			if sinceLastTick > 80 {
+				timeBetweenTiny := sinceLastTick
+				for timeBetweenTiny > 100 {
+					timeBetweenTiny /= 2
+				}
+
+				for t := timeBetweenTiny; t < sinceLastTick; t += timeBetweenTiny {
+					progress := last.PathProgress + (t/sinceLastTick)*(ev.PathProgress-last.PathProgress)
+					stream = append(stream, CatchObject{Type: CATCH_TINY_DROPLET, StartTime: t + last.Time, X: obj.PositionAt(progress).x})
+				}
+			}
+		}
+
+		// the legacy last tick isn't an object, but tiny droplets are still
+		// placed up to it
+		event := ev
+		last = &event
+
+		switch ev.Kind {
+		case SLIDER_TICK:
+			stream = append(stream, CatchObject{Type: CATCH_DROPLET, StartTime: ev.Time, X: ev.Position.x})
+		case SLIDER_HEAD, SLIDER_REPEAT, SLIDER_TAIL:
+			stream = append(stream, CatchObject{Type: CATCH_FRUIT, StartTime: ev.Time, X: ev.Position.x})
+		}
+	}
+	return
+}
+
+// applyHardRockOffset nudges fruits that are close together in time further
+// apart, or randomly if they're in the same place.
+func applyHardRockOffset(fruit *CatchObject, lastPosition **float64, lastStartTime *float64, rng *legacyRandom) {
+	position := fruit.X
+	if *lastPosition == nil {
+		*lastPosition = &position
+		*lastStartTime = fruit.StartTime
+		return
+	}
+
+	positionDiff := position - **lastPosition
+	timeDiff := int(fruit.StartTime - *lastStartTime)
+
+	if timeDiff > 1000 {
+		*lastPosition = &position
+		*lastStartTime = fruit.StartTime
+		return
+	}
+
+	if positionDiff == 0 {
+		right := rng.nextBool()
+		offset := math.Min(20, float64(rng.nextRangeFloat(0, math.Max(0, float64(timeDiff)/4))))
+		if right && position+offset > CATCH_PLAYFIELD_WIDTH || !right && position-offset >= 0 {
+			position -= offset
+		} else {
+			position += offset
+		}
+		fruit.X = position
+		return
+	}
+
+	// the game divides whole milliseconds here
+	if math.Abs(positionDiff) < float64(timeDiff/3) {
+		if positionDiff > 0 {
+			if position+positionDiff < CATCH_PLAYFIELD_WIDTH {
+				position += positionDiff
+			}
+		} else if position+positionDiff > 0 {
+			position += positionDiff
+		}
+	}
+
+	fruit.X = position
+	*lastPosition = &position
+	*lastStartTime = fruit.StartTime
+}
+
+// initialiseHyperDash works out which objects need a hyperdash to reach the
+// next one. Tiny droplets and bananas are ignored, since missing them doesn't
+// break combo.
+func initialiseHyperDash(objects []CatchObject, halfCatcherWidth float64) {
+	var palpable []*CatchObject
+	for i := range objects {
+		if objects[i].Type == CATCH_FRUIT || objects[i].Type == CATCH_DROPLET {
+			palpable = append(palpable, &objects[i])
+		}
+	}
+
+	// the game used the full catcher width here, without the margins
+	halfCatcherWidth /= CATCHER_ALLOWED_CATCH_RANGE
+
+	lastDirection := 0
+	lastExcess := halfCatcherWidth
+
+	for i := 0; i+1 < len(palpable); i++ {
+		curr, next := palpable[i], palpable[i+1]
+		curr.HyperDash = false
+		curr.DistanceToHyperDash = 0
+
+		direction := -1
+		if next.X > curr.X {
+			direction = 1
+		}
+
+		// a quarter of a frame of leeway
+		timeToNext := float64(int(next.StartTime)-int(curr.StartTime)) - 1000.0/60/4
+		excess := halfCatcherWidth
+		if lastDirection == direction {
+			excess = lastExcess
+		}
+		distanceToNext := math.Abs(next.X-curr.X) - excess
+		distanceToHyper := timeToNext - distanceToNext
+
+		if distanceToHyper < 0 {
+			curr.HyperDash = true
+			lastExcess = halfCatcherWidth
+		} else {
+			curr.DistanceToHyperDash = distanceToHyper
+			lastExcess = math.Max(0, math.Min(halfCatcherWidth, distanceToHyper))
+		}
+		lastDirection = direction
+	}
+}
+
+// legacyRandom is the xorshift random number generator that the game has
+// always used, which has to be reproduced exactly to get the same positions.
+type legacyRandom struct {
+	x, y, z, w uint32
+	bitBuffer  uint32
+	bitIndex   int
+}
+
+func newLegacyRandom(seed int) *legacyRandom {
+	return &legacyRandom{x: uint32(seed), y: 842502087, z: 3579807591, w: 273326509, bitIndex: 32}
+}
+
+func (r *legacyRandom) nextUint() uint32 {
+	t := r.x ^ (r.x << 11)
+	r.x, r.y, r.z = r.y, r.z, r.w
+	r.w = r.w ^ (r.w >> 19) ^ t ^ (t >> 8)
+	return r.w
+}
+
+func (r *legacyRandom) next() int {
+	return int(r.nextUint() & 0x7FFFFFFF)
+}
+
+func (r *legacyRandom) nextDouble() float64 {
+	return float64(r.next()) / (math.MaxInt32 + 1.0)
+}
+
+// nextRange returns a number from lower up to but not including upper.
+func (r *legacyRandom) nextRange(lower, upper int) int {
+	return int(float64(lower) + r.nextDouble()*float64(upper-lower))
+}
+
+// nextRangeFloat is nextRange with fractional bounds, which are only rounded
+// down after picking a number.
+func (r *legacyRandom) nextRangeFloat(lower, upper float64) int {
+	return int(lower + r.nextDouble()*(upper-lower))
+}
+
+func (r *legacyRandom) nextBool() bool {
+	if r.bitIndex == 32 {
+		r.bitBuffer = r.nextUint()
+		r.bitIndex = 1
+		return r.bitBuffer&1 == 1
+	}
+	r.bitIndex++
+	r.bitBuffer >>= 1
+	return r.bitBuffer&1 == 1
+}
@@ -0,0 +1,147 @@
+package osu
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const catchConvertMap = `osu file format v14
+
+[Difficulty]
+CircleSize:5
+SliderMultiplier:1
+SliderTickRate:1
+
+[TimingPoints]
+0,500,4,2,0,100,1,0
+
+[HitObjects]
+0,192,0,1,0,0:0:0:0:
+512,192,100,1,0,0:0:0:0:
+256,192,1000,1,0,0:0:0:0:
+0,192,2000,2,0,L|200:192,1,200
+256,192,4000,12,0,5000,0:0:0:0:
+`
+
+func TestCatchObjects(t *testing.T) {
+	m, err := ParseBeatmap(strings.NewReader(catchConvertMap))
+	if err != nil {
+		t.Fatalf("failed to parse map: %v", err)
+	}
+
+	objects := m.CatchObjects(m.CircleSize, false)
+	counts := map[CatchObjectType]int{}
+	for i, obj := range objects {
+		counts[obj.Type]++
+		if obj.X < 0 || obj.X > CATCH_PLAYFIELD_WIDTH {
+			t.Errorf("object %d is outside the playfield: %+v", i, obj)
+		}
+		if i > 0 && obj.StartTime < objects[i-1].StartTime {
+			t.Errorf("object %d is out of order: %+v", i, obj)
+		}
+	}
+
+	// the slider has a fruit at each end and a droplet for the tick in the
+	// middle, with tiny droplets in between, and the spinner has a banana
+	// every 62.5ms
+	expected := map[CatchObjectType]int{
+		CATCH_FRUIT:        5,
+		CATCH_DROPLET:      1,
+		CATCH_TINY_DROPLET: 14,
+		CATCH_BANANA:       17,
+	}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("expected %v, got %v", expected, counts)
+	}
+
+	if !objects[0].HyperDash {
+		t.Error("expected a hyperdash across the whole playfield")
+	}
+	if objects[1].HyperDash || objects[1].DistanceToHyperDash <= 0 {
+		t.Errorf("expected no hyperdash with plenty of time, got %+v", objects[1])
+	}
+
+	droplet := objects[11]
+	if droplet.Type != CATCH_DROPLET || droplet.StartTime != 2500 || droplet.X != 100 {
+		t.Errorf("wrong droplet: %+v", droplet)
+	}
+	if tiny := objects[4]; tiny.Type != CATCH_TINY_DROPLET || tiny.StartTime != 2062.5 || math.Abs(tiny.X-12.5) > 20 {
+		t.Errorf("wrong tiny droplet: %+v", tiny)
+	}
+
+	// the positions are random, but always the same
+	if again := m.CatchObjects(m.CircleSize, false); !reflect.DeepEqual(objects, again) {
+		t.Error("expected the same objects every time")
+	}
+	if hardRock := m.CatchObjects(m.CircleSize, true); hardRock[0].X != 0 || len(hardRock) != len(objects) {
+		t.Errorf("expected the first fruit to stay put, got %+v", hardRock[0])
+	}
+}
+
+const catchHardRockMap = `osu file format v14
+
+[HitObjects]
+256,192,1000,1,0,0:0:0:0:
+256,192,1001,1,0,0:0:0:0:
+256,192,1010,1,0,0:0:0:0:
+256,192,3000,1,0,0:0:0:0:
+256,192,3200,1,0,0:0:0:0:
+256,192,3210,1,0,0:0:0:0:
+`
+
+func TestCatchHardRock(t *testing.T) {
+	m, err := ParseBeatmap(strings.NewReader(catchHardRockMap))
+	if err != nil {
+		t.Fatalf("failed to parse map: %v", err)
+	}
+
+	// fruits in the same place as the one before are moved by up to a
+	// quarter of the time since it, which isn't rounded until after the
+	// random number is picked, and the fruits after are still compared with
+	// the first one
+	expected := []float64{256, 256, 255, 256, 236, 236}
+	objects := m.CatchObjects(5, true)
+	if len(objects) != len(expected) {
+		t.Fatalf("expected %d objects, got %d", len(expected), len(objects))
+	}
+	for i, obj := range objects {
+		if obj.X != expected[i] {
+			t.Errorf("expected fruit %d at %v, got %v", i, expected[i], obj.X)
+		}
+	}
+}
+
+func TestLegacyRandom(t *testing.T) {
+	// values worked out by hand from the xorshift algorithm
+	r := newLegacyRandom(CATCH_RNG_SEED)
+	for i, expected := range []uint32{274941776, 2661595948, 3085529888} {
+		if n := r.nextUint(); n != expected {
+			t.Errorf("expected uint %d to be %d, got %d", i, expected, n)
+		}
+	}
+	for i, expected := range []float64{0.8978247311897576, 0.94312804332003} {
+		if x := r.nextDouble(); x != expected {
+			t.Errorf("expected double %d to be %v, got %v", i, expected, x)
+		}
+	}
+	// bools are taken from the bits of 1132384915, lowest first
+	for i, expected := range []bool{true, true, false, false, true} {
+		if b := r.nextBool(); b != expected {
+			t.Errorf("expected bool %d to be %v, got %v", i, expected, b)
+		}
+	}
+
+	a, b := newLegacyRandom(CATCH_RNG_SEED), newLegacyRandom(CATCH_RNG_SEED)
+	for i := 0; i < 100; i++ {
+		x := a.nextDouble()
+		if x < 0 || x >= 1 || x != b.nextDouble() {
+			t.Fatalf("wrong random number %v", x)
+		}
+		if n := a.nextRange(-20, 20); n < -20 || n >= 20 {
+			t.Fatalf("random number %d out of range", n)
+		}
+		b.nextRange(-20, 20)
+	}
+}
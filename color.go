@@ -1,5 +1,33 @@
 package osu
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 type Color struct {
 	R, G, B int
 }
+
+func ParseColor(line string) (color Color, err error) {
+	parts := strings.Split(line, ",")
+	if len(parts) < 3 {
+		return Color{}, fmt.Errorf("len(color) = %d < 3", len(parts))
+	}
+
+	if color.R, err = strconv.Atoi(strings.TrimSpace(parts[0])); err != nil {
+		return
+	}
+	if color.G, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil {
+		return
+	}
+	if color.B, err = strconv.Atoi(strings.TrimSpace(parts[2])); err != nil {
+		return
+	}
+	return
+}
+
+func (color Color) String() string {
+	return fmt.Sprintf("%d,%d,%d", color.R, color.G, color.B)
+}
@@ -0,0 +1,48 @@
+package osu
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const coloursSection = `osu file format v14
+
+[Colours]
+Combo3 : 0,128,255
+Combo1 : 255,128,0
+SliderTrackOverride : 1,2,3
+SliderBorder : 4,5,6
+SpinnerApproachCircle : 7,8,9
+`
+
+func TestColours(t *testing.T) {
+	m, err := ParseBeatmap(strings.NewReader(coloursSection))
+	if err != nil {
+		t.Fatalf("failed to parse colours: %v", err)
+	}
+
+	if len(m.Colors) != 2 || m.Colors[0] != (Color{255, 128, 0}) || m.Colors[1] != (Color{0, 128, 255}) {
+		t.Errorf("wrong combo colours: %+v", m.Colors)
+	}
+	if m.SliderTrackOverride == nil || *m.SliderTrackOverride != (Color{1, 2, 3}) {
+		t.Errorf("wrong slider track colour: %+v", m.SliderTrackOverride)
+	}
+	if m.SliderBorder == nil || *m.SliderBorder != (Color{4, 5, 6}) {
+		t.Errorf("wrong slider border colour: %+v", m.SliderBorder)
+	}
+
+	if len(m.ColorNumbers) != 2 || m.ColorNumbers[0] != 1 || m.ColorNumbers[1] != 3 {
+		t.Errorf("wrong combo colour numbers: %v", m.ColorNumbers)
+	}
+
+	// colours keep their numbers, and ones we don't know about are kept too
+	var buf bytes.Buffer
+	if err := m.Serialize(&buf); err != nil {
+		t.Fatalf("failed to serialize: %v", err)
+	}
+	expected := "[Colours]\nCombo1 : 255,128,0\nCombo3 : 0,128,255\nSliderTrackOverride : 1,2,3\nSliderBorder : 4,5,6\nSpinnerApproachCircle : 7,8,9\n"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("wrong [Colours] section:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,57 @@
+package osu
+
+// ComboInfo describes where an object falls in the map's combos.
+type ComboInfo struct {
+	// ComboNumber counts the combos in the map, starting from 1
+	ComboNumber int
+
+	// IndexInCombo is the position of the object within its combo, starting
+	// from 0. The number drawn on the object is one more than this.
+	IndexInCombo int
+
+	// ColorIndex is the index into Beatmap.Colors of the combo's colour, or -1
+	// if the map doesn't have any colours
+	ColorIndex int
+}
+
+// Combos works out the combo information for each of the hit objects, in the
+// same order as HitObjects.
+func (m *Beatmap) Combos() []ComboInfo {
+	combos := make([]ComboInfo, len(m.HitObjects))
+
+	// spinners don't start a new combo themselves; instead they force the next
+	// object to start one, and pass on their colour skip to it
+	forceNewCombo := false
+	extraSkip := 0
+
+	comboNumber, colorOffset, index := 0, 0, 0
+	for i, obj := range m.HitObjects {
+		newCombo, comboSkip := (*obj).IsNewCombo(), (*obj).GetComboSkip()
+
+		if (*obj).GetType() == OBJ_SPINNER {
+			forceNewCombo = forceNewCombo || newCombo || m.Version <= 8
+			extraSkip += comboSkip
+			newCombo = false
+		} else {
+			// the first object always starts a combo
+			newCombo = newCombo || forceNewCombo || i == 0
+			comboSkip += extraSkip
+			forceNewCombo, extraSkip = false, 0
+		}
+
+		if newCombo || comboNumber == 0 {
+			comboNumber++
+			colorOffset += comboSkip + 1
+			index = 0
+		} else {
+			index++
+		}
+
+		colorIndex := -1
+		if len(m.Colors) > 0 {
+			colorIndex = (colorOffset - 1) % len(m.Colors)
+		}
+		combos[i] = ComboInfo{comboNumber, index, colorIndex}
+	}
+	return combos
+}
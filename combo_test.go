@@ -0,0 +1,51 @@
+package osu
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const comboMap = `osu file format v14
+
+[Colours]
+Combo1 : 255,0,0
+Combo2 : 0,255,0
+Combo3 : 0,0,255
+
+[HitObjects]
+0,0,1000,1,0,0:0:0:0:
+0,0,1100,1,0,0:0:0:0:
+0,0,1200,5,0,0:0:0:0:
+0,0,1300,37,0,0:0:0:0:
+0,0,1400,1,0,0:0:0:0:
+256,192,1500,12,0,2000,0:0:0:0:
+0,0,2100,1,0,0:0:0:0:
+`
+
+func TestCombos(t *testing.T) {
+	m, err := ParseBeatmap(strings.NewReader(comboMap))
+	if err != nil {
+		t.Fatalf("failed to parse beatmap: %v", err)
+	}
+
+	// the fourth object skips 2 colours, and the spinner forces a new combo
+	// on the object after it
+	expected := []ComboInfo{
+		{1, 0, 0},
+		{1, 1, 0},
+		{2, 0, 1},
+		{3, 0, 1},
+		{3, 1, 1},
+		{3, 2, 1},
+		{4, 0, 2},
+	}
+	if combos := m.Combos(); !reflect.DeepEqual(combos, expected) {
+		t.Errorf("expected %v, got %v", expected, combos)
+	}
+
+	// the skip survives serialization
+	if line, _ := (*m.HitObjects[3]).Serialize(); line != "0,0,1300,37,0,0:0:0:0:" {
+		t.Errorf("wrong serialization: '%s'", line)
+	}
+}
@@ -0,0 +1,157 @@
+package difficulty
+
+import (
+	"math"
+
+	osu "github.com/iptq/osu-go"
+)
+
+// CatchAttributes describes the difficulty of an osu!catch map.
+type CatchAttributes struct {
+	StarRating   float64
+	ApproachRate float64
+
+	// MaxCombo counts fruits and droplets, since tiny droplets and bananas
+	// don't give any combo
+	MaxCombo int
+}
+
+const (
+	CATCH_STAR_SCALING_FACTOR = 0.153
+
+	CATCH_NORMALISED_HITOBJECT_RADIUS        = 41.0
+	CATCH_ABSOLUTE_PLAYER_POSITIONING_ERROR  = 16.0
+	CATCH_DIRECTION_CHANGE_BONUS             = 21.0
+	CATCH_MOVEMENT_SKILL_MULTIPLIER          = 900.0
+	CATCH_MOVEMENT_STRAIN_DECAY_BASE         = 0.2
+	CATCH_MOVEMENT_DECAY_WEIGHT              = 0.94
+	CATCH_MOVEMENT_SECTION_LENGTH            = 750.0
+	CATCH_MOVEMENT_EDGE_DASH_THRESHOLD       = 20.0
+	CATCH_MOVEMENT_EDGE_DASH_BONUS           = 5.7
+	CATCH_MOVEMENT_EDGE_DASH_MAX_STRAIN_TIME = 265.0
+)
+
+type catchDifficultyObject struct {
+	obj, last osu.CatchObject
+
+	startTime  float64
+	deltaTime  float64
+	strainTime float64
+
+	// positions scaled so that the catcher is always the same size
+	normalisedPosition     float64
+	lastNormalisedPosition float64
+}
+
+// CalculateCatch calculates the difficulty of a map played in osu!catch,
+// converting it first if it was made for osu!standard.
+func CalculateCatch(m *osu.Beatmap, mods Mods) CatchAttributes {
+	settings := AdjustedSettings(m, mods)
+	clockRate := ClockRate(mods)
+	objects := m.CatchObjects(settings.CircleSize, mods&MOD_HARDROCK != 0)
+
+	preempt := osu.DifficultyRange(settings.ApproachRate, 1800, 1200, 450) / clockRate
+	attrs := CatchAttributes{ApproachRate: approachRateFromPreempt(preempt)}
+
+	// the catcher is made a bit smaller for high circle sizes, since nobody
+	// catches everything perfectly
+	halfCatcherWidth := osu.CatcherWidth(settings.CircleSize) / 2
+	halfCatcherWidth *= 1 - math.Max(0, settings.CircleSize-5.5)*0.0625
+	scale := CATCH_NORMALISED_HITOBJECT_RADIUS / halfCatcherWidth
+
+	var diffObjects []*catchDifficultyObject
+	var last *osu.CatchObject
+	for i, obj := range objects {
+		if obj.Type != osu.CATCH_FRUIT && obj.Type != osu.CATCH_DROPLET {
+			continue
+		}
+		attrs.MaxCombo++
+
+		if last != nil {
+			deltaTime := (obj.StartTime - last.StartTime) / clockRate
+			diffObjects = append(diffObjects, &catchDifficultyObject{
+				obj:                    obj,
+				last:                   *last,
+				startTime:              obj.StartTime / clockRate,
+				deltaTime:              deltaTime,
+				strainTime:             math.Max(40, deltaTime),
+				normalisedPosition:     obj.X * scale,
+				lastNormalisedPosition: last.X * scale,
+			})
+		}
+		last = &objects[i]
+	}
+
+	movement := newCatchMovementSkill(diffObjects, clockRate)
+	for i, d := range diffObjects {
+		movement.process(i, d.startTime)
+	}
+
+	attrs.StarRating = math.Sqrt(weightedSum(movement.strainPeaks(), CATCH_MOVEMENT_DECAY_WEIGHT)) * CATCH_STAR_SCALING_FACTOR
+	return attrs
+}
+
+// newCatchMovementSkill measures how far and how quickly the catcher has to
+// move, with extra for changing direction and for dashes that only just make
+// it.
+func newCatchMovementSkill(objects []*catchDifficultyObject, clockRate float64) *strainSkill {
+	startTimes := make([]float64, len(objects))
+	for i, d := range objects {
+		startTimes[i] = d.startTime
+	}
+
+	var lastPlayerPosition *float64
+	lastDistanceMoved, lastStrainTime := 0.0, 0.0
+
+	strainValueOf := func(i int) float64 {
+		d := objects[i]
+		if lastPlayerPosition == nil {
+			lastPlayerPosition = &d.lastNormalisedPosition
+		}
+
+		// the player only has to move far enough to catch the fruit at the
+		// edge of the catcher
+		leeway := CATCH_NORMALISED_HITOBJECT_RADIUS - CATCH_ABSOLUTE_PLAYER_POSITIONING_ERROR
+		playerPosition := clamp(*lastPlayerPosition, d.normalisedPosition-leeway, d.normalisedPosition+leeway)
+		distanceMoved := playerPosition - *lastPlayerPosition
+
+		weightedStrainTime := d.strainTime + 13 + 3/clockRate
+		distanceAddition := math.Pow(math.Abs(distanceMoved), 1.3) / 510
+		sqrtStrain := math.Sqrt(weightedStrainTime)
+
+		if math.Abs(distanceMoved) > 0.1 {
+			if math.Abs(lastDistanceMoved) > 0.1 && math.Signbit(distanceMoved) != math.Signbit(lastDistanceMoved) {
+				bonusFactor := math.Min(50, math.Abs(distanceMoved)) / 50
+				antiflowFactor := math.Max(math.Min(70, math.Abs(lastDistanceMoved))/70, 0.38)
+				distanceAddition += CATCH_DIRECTION_CHANGE_BONUS / math.Sqrt(lastStrainTime+16) * bonusFactor * antiflowFactor * math.Max(1-math.Pow(weightedStrainTime/1000, 3), 0)
+			}
+
+			// every movement counts for something, so streams aren't free
+			distanceAddition += 12.5 * math.Min(math.Abs(distanceMoved), CATCH_NORMALISED_HITOBJECT_RADIUS*2) / (CATCH_NORMALISED_HITOBJECT_RADIUS * 6) / sqrtStrain
+		}
+
+		if d.last.DistanceToHyperDash <= CATCH_MOVEMENT_EDGE_DASH_THRESHOLD {
+			edgeDashBonus := 0.0
+			if !d.last.HyperDash {
+				edgeDashBonus += CATCH_MOVEMENT_EDGE_DASH_BONUS
+			} else {
+				// a hyperdash always lands in the right place
+				playerPosition = d.normalisedPosition
+			}
+
+			// edge dashes are easier when the objects are closer together
+			closeness := math.Min(d.strainTime*clockRate, CATCH_MOVEMENT_EDGE_DASH_MAX_STRAIN_TIME) / CATCH_MOVEMENT_EDGE_DASH_MAX_STRAIN_TIME
+			distanceAddition *= 1 + edgeDashBonus*((CATCH_MOVEMENT_EDGE_DASH_THRESHOLD-d.last.DistanceToHyperDash)/CATCH_MOVEMENT_EDGE_DASH_THRESHOLD)*math.Pow(closeness, 1.5)
+		}
+
+		lastPlayerPosition = &playerPosition
+		lastDistanceMoved = distanceMoved
+		lastStrainTime = d.strainTime
+		return distanceAddition / weightedStrainTime
+	}
+
+	skill := strainDecaySkill(startTimes, CATCH_MOVEMENT_STRAIN_DECAY_BASE, CATCH_MOVEMENT_SKILL_MULTIPLIER,
+		func(i int) float64 { return objects[i].deltaTime }, strainValueOf)
+	skill.sectionLength = CATCH_MOVEMENT_SECTION_LENGTH
+	return skill
+}
@@ -0,0 +1,47 @@
+package difficulty
+
+import (
+	"testing"
+
+	osu "github.com/iptq/osu-go"
+)
+
+func TestCatchDifficulty(t *testing.T) {
+	easy := CalculateCatch(loadMap(t, "David Wise - Gang-Plank Galleon (Hara) [v0xy style].osu"), 0)
+	m := loadMap(t, "David Wise - Gang-Plank Galleon (Hara) [Larry Kong].osu")
+	nomod := CalculateCatch(m, 0)
+	if easy.StarRating >= nomod.StarRating {
+		t.Errorf("expected %.2f stars to be less than %.2f", easy.StarRating, nomod.StarRating)
+	}
+	if nomod.StarRating < 1.5 || nomod.StarRating > 3 {
+		t.Errorf("expected around 2 stars, got %.2f", nomod.StarRating)
+	}
+	if nomod.ApproachRate != m.ApproachRate {
+		t.Errorf("expected AR %v, got %v", m.ApproachRate, nomod.ApproachRate)
+	}
+
+	combo := 0
+	for _, obj := range m.CatchObjects(m.CircleSize, false) {
+		if obj.Type == osu.CATCH_FRUIT || obj.Type == osu.CATCH_DROPLET {
+			combo++
+		}
+	}
+	if nomod.MaxCombo != combo || nomod.MaxCombo != 412 {
+		t.Errorf("expected max combo %d, got %d", combo, nomod.MaxCombo)
+	}
+	expectClose(t, "star rating", nomod.StarRating, 2.1155, 1e-4)
+
+	for _, test := range []struct {
+		mods     Mods
+		expected float64
+	}{
+		{MOD_HARDROCK, 3.3531},
+		{MOD_DOUBLETIME, 2.8909},
+	} {
+		attrs := CalculateCatch(m, test.mods)
+		if attrs.ApproachRate <= nomod.ApproachRate {
+			t.Errorf("mods %d: expected a higher AR, got %+v", test.mods, attrs)
+		}
+		expectClose(t, "star rating with mods", attrs.StarRating, test.expected, 1e-4)
+	}
+}
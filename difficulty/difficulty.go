@@ -0,0 +1,174 @@
+// Package difficulty calculates star ratings for beatmaps, using the same
+// strain-based model as the game.
+package difficulty
+
+import (
+	"math"
+	"sort"
+
+	osu "github.com/iptq/osu-go"
+)
+
+type Mods = int
+
+// Mods that change difficulty, with the same values as in replays and scores
+const (
+	MOD_NOFAIL      = 1 << 0
+	MOD_EASY        = 1 << 1
+	MOD_TOUCHDEVICE = 1 << 2
+	MOD_HIDDEN      = 1 << 3
+	MOD_HARDROCK    = 1 << 4
+	MOD_SUDDENDEATH = 1 << 5
+	MOD_DOUBLETIME  = 1 << 6
+	MOD_RELAX       = 1 << 7
+	MOD_HALFTIME    = 1 << 8
+	MOD_NIGHTCORE   = 1 << 9
+	MOD_FLASHLIGHT  = 1 << 10
+	MOD_SPUNOUT     = 1 << 12
+	MOD_AUTOPILOT   = 1 << 13
+	MOD_PERFECT     = 1 << 14
+	MOD_KEY4        = 1 << 15
+	MOD_KEY5        = 1 << 16
+	MOD_KEY6        = 1 << 17
+	MOD_KEY7        = 1 << 18
+	MOD_KEY8        = 1 << 19
+	MOD_FADEIN      = 1 << 20
+	MOD_RANDOM      = 1 << 21
+	MOD_KEY9        = 1 << 24
+	MOD_KEYCOOP     = 1 << 25
+	MOD_KEY1        = 1 << 26
+	MOD_KEY3        = 1 << 27
+	MOD_KEY2        = 1 << 28
+	MOD_SCOREV2     = 1 << 29
+	MOD_MIRROR      = 1 << 30
+)
+
+// ClockRate returns how much faster than normal the map plays with the given
+// mods.
+func ClockRate(mods Mods) float64 {
+	switch {
+	case mods&(MOD_DOUBLETIME|MOD_NIGHTCORE) != 0:
+		return 1.5
+	case mods&MOD_HALFTIME != 0:
+		return 0.75
+	}
+	return 1
+}
+
+// Settings are the difficulty settings of a map after applying mods.
+type Settings struct {
+	CircleSize        float64
+	ApproachRate      float64
+	OverallDifficulty float64
+	HPDrainRate       float64
+}
+
+// AdjustedSettings applies the effects of Hard Rock and Easy to the map's
+// difficulty settings. Speed changing mods aren't taken into account here.
+func AdjustedSettings(m *osu.Beatmap, mods Mods) Settings {
+	s := Settings{m.CircleSize, m.ApproachRate, m.OverallDifficulty, m.HPDrainRate}
+	switch {
+	case mods&MOD_HARDROCK != 0:
+		s.CircleSize = math.Min(10, s.CircleSize*1.3)
+		s.ApproachRate = math.Min(10, s.ApproachRate*1.4)
+		s.OverallDifficulty = math.Min(10, s.OverallDifficulty*1.4)
+		s.HPDrainRate = math.Min(10, s.HPDrainRate*1.4)
+	case mods&MOD_EASY != 0:
+		s.CircleSize *= 0.5
+		s.ApproachRate *= 0.5
+		s.OverallDifficulty *= 0.5
+		s.HPDrainRate *= 0.5
+	}
+	return s
+}
+
+func clamp(x, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, x))
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
+
+// the length of the sections that strain peaks are taken from, in milliseconds
+const SECTION_LENGTH = 400.0
+
+// strainSkill keeps track of the highest strain in each section of a map.
+// Every object has to be passed to process in order.
+type strainSkill struct {
+	// strainValueAt works out the strain at the object with the given index,
+	// and initialStrain the strain at the start of a section, given the index
+	// of the object that comes after it
+	strainValueAt func(i int) float64
+	initialStrain func(time float64, i int) float64
+
+	// sectionLength is SECTION_LENGTH unless it's set
+	sectionLength float64
+
+	peaks              []float64
+	currentSectionPeak float64
+	currentSectionEnd  float64
+}
+
+func (s *strainSkill) process(i int, startTime float64) {
+	if s.sectionLength == 0 {
+		s.sectionLength = SECTION_LENGTH
+	}
+	if i == 0 {
+		s.currentSectionEnd = math.Ceil(startTime/s.sectionLength) * s.sectionLength
+	}
+
+	for startTime > s.currentSectionEnd {
+		s.peaks = append(s.peaks, s.currentSectionPeak)
+		s.currentSectionPeak = s.initialStrain(s.currentSectionEnd, i)
+		s.currentSectionEnd += s.sectionLength
+	}
+
+	s.currentSectionPeak = math.Max(s.strainValueAt(i), s.currentSectionPeak)
+}
+
+// strainPeaks returns the peak strain of each section so far, including the
+// one that's still in progress.
+func (s *strainSkill) strainPeaks() []float64 {
+	return append(append([]float64(nil), s.peaks...), s.currentSectionPeak)
+}
+
+// strainDecaySkill adds each object's strain on top of what's left over from
+// the previous objects.
+func strainDecaySkill(startTimes []float64, decayBase, multiplier float64, deltaTime func(i int) float64, strainValueOf func(i int) float64) *strainSkill {
+	currentStrain := 0.0
+	return &strainSkill{
+		strainValueAt: func(i int) float64 {
+			currentStrain *= strainDecay(decayBase, deltaTime(i))
+			currentStrain += strainValueOf(i) * multiplier
+			return currentStrain
+		},
+		initialStrain: func(time float64, i int) float64 {
+			return currentStrain * strainDecay(decayBase, time-startTimes[i-1])
+		},
+	}
+}
+
+// weightedSum adds up the values from highest to lowest, with each one worth
+// decayWeight times as much as the last.
+func weightedSum(values []float64, decayWeight float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sortDescending(sorted)
+
+	total, weight := 0.0, 1.0
+	for _, v := range sorted {
+		total += v * weight
+		weight *= decayWeight
+	}
+	return total
+}
+
+func sortDescending(values []float64) {
+	sort.Sort(sort.Reverse(sort.Float64Slice(values)))
+}
+
+// strainDecay returns how much strain is left after the given time, if it
+// goes down to base after a second.
+func strainDecay(base, ms float64) float64 {
+	return math.Pow(base, ms/1000)
+}
@@ -0,0 +1,37 @@
+package difficulty
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	osu "github.com/iptq/osu-go"
+)
+
+func loadMap(t *testing.T, name string) *osu.Beatmap {
+	f, err := os.Open("../test/" + name)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", name, err)
+	}
+	defer f.Close()
+
+	m, err := osu.ParseBeatmap(f)
+	if err != nil {
+		t.Fatalf("failed to parse %s: %v", name, err)
+	}
+	return m
+}
+
+// expectClose checks a value against a known one, allowing for a relative
+// difference of tolerance.
+//
+// TODO: the star ratings the tests expect are what this package gave when it
+// was written, since the game's own calculator couldn't be run to check them.
+// They catch changes, but not mistakes that were there from the start, so
+// they should be replaced with the game's values.
+func expectClose(t *testing.T, name string, got, expected, tolerance float64) {
+	t.Helper()
+	if math.Abs(got-expected) > tolerance*math.Abs(expected) {
+		t.Errorf("expected %s to be %v, got %v", name, expected, got)
+	}
+}
@@ -0,0 +1,157 @@
+package difficulty
+
+import (
+	"errors"
+	"math"
+
+	osu "github.com/iptq/osu-go"
+)
+
+// ManiaAttributes describes the difficulty of an osu!mania map.
+type ManiaAttributes struct {
+	StarRating float64
+
+	// GreatHitWindow is the 300 window either side of a note, in
+	// milliseconds and adjusted for the clock rate
+	GreatHitWindow float64
+
+	NoteCount     int
+	HoldNoteCount int
+
+	// MaxCombo counts hold notes twice, once for the head and once for the
+	// tail
+	MaxCombo int
+}
+
+const (
+	MANIA_STAR_SCALING_FACTOR = 0.018
+
+	MANIA_INDIVIDUAL_DECAY_BASE = 0.125
+	MANIA_OVERALL_DECAY_BASE    = 0.30
+
+	// how far apart, in milliseconds, two releases have to be for the second
+	// to count as much as a separate note
+	MANIA_RELEASE_THRESHOLD = 24.0
+)
+
+type maniaDifficultyObject struct {
+	note osu.ManiaNote
+
+	startTime float64
+	endTime   float64
+	deltaTime float64
+}
+
+// CalculateMania calculates the difficulty of an osu!mania map. Maps made for
+// other modes can't be converted yet.
+func CalculateMania(m *osu.Beatmap, mods Mods) (ManiaAttributes, error) {
+	if m.Mode != osu.MODE_MANIA {
+		return ManiaAttributes{}, errors.New("beatmap is not an osu!mania map")
+	}
+
+	clockRate := ClockRate(mods)
+	notes := m.ManiaNotes()
+
+	attrs := ManiaAttributes{GreatHitWindow: maniaGreatHitWindow(m.OverallDifficulty, mods, clockRate)}
+	for _, note := range notes {
+		if note.Hold {
+			attrs.HoldNoteCount++
+			attrs.MaxCombo += 2
+		} else {
+			attrs.NoteCount++
+			attrs.MaxCombo++
+		}
+	}
+
+	var diffObjects []*maniaDifficultyObject
+	for i := 1; i < len(notes); i++ {
+		diffObjects = append(diffObjects, &maniaDifficultyObject{
+			note:      notes[i],
+			startTime: notes[i].StartTime / clockRate,
+			endTime:   notes[i].EndTime / clockRate,
+			deltaTime: (notes[i].StartTime - notes[i-1].StartTime) / clockRate,
+		})
+	}
+
+	strain := newManiaStrainSkill(diffObjects, m.KeyCount())
+	for i, d := range diffObjects {
+		strain.process(i, d.startTime)
+	}
+
+	attrs.StarRating = weightedSum(strain.strainPeaks(), 0.9) * MANIA_STAR_SCALING_FACTOR
+	return attrs, nil
+}
+
+// maniaGreatHitWindow works out the 300 window the same way the game always
+// has, which is why the clock rate is applied twice.
+func maniaGreatHitWindow(overallDifficulty float64, mods Mods, clockRate float64) float64 {
+	window := 34 + 3*clamp(10-overallDifficulty, 0, 10)
+	if mods&MOD_HARDROCK != 0 {
+		window /= 1.4
+	} else if mods&MOD_EASY != 0 {
+		window *= 1.4
+	}
+	window *= clockRate
+	return math.Ceil(float64(int(window*clockRate)) / clockRate)
+}
+
+// newManiaStrainSkill combines the strain on each hand, which builds up in
+// each column separately, with the strain of the map as a whole. Holding
+// notes down makes everything else harder.
+func newManiaStrainSkill(objects []*maniaDifficultyObject, keyCount int) *strainSkill {
+	startTimes := make([]float64, keyCount)
+	endTimes := make([]float64, keyCount)
+	individualStrains := make([]float64, keyCount)
+	individualStrain, overallStrain := 0.0, 1.0
+
+	return &strainSkill{
+		strainValueAt: func(i int) float64 {
+			d := objects[i]
+			column := d.note.Column
+
+			// closestEndTime starts as the lowest value we can assume, and is
+			// then lowered by any hold notes that end closer by
+			isOverlapping := false
+			lastStartTime := d.startTime - d.deltaTime
+			closestEndTime := math.Abs(d.endTime - lastStartTime)
+			holdFactor, holdAddition := 1.0, 0.0
+			for c := range endTimes {
+				// this note is overlapped if something else is let go while
+				// it's held, and everything is easier while something else is
+				// held down
+				isOverlapping = isOverlapping || endTimes[c]-1 > d.startTime && d.endTime-1 > endTimes[c]
+				if endTimes[c]-1 > d.endTime {
+					holdFactor = 1.25
+				}
+				closestEndTime = math.Min(closestEndTime, math.Abs(d.endTime-endTimes[c]))
+			}
+
+			// releasing a lot of notes together is as easy as releasing one,
+			// so the bonus only really applies if nothing else ends nearby
+			if isOverlapping {
+				holdAddition = 1 / (1 + math.Exp(0.5*(MANIA_RELEASE_THRESHOLD-closestEndTime)))
+			}
+
+			individualStrains[column] *= strainDecay(MANIA_INDIVIDUAL_DECAY_BASE, d.startTime-startTimes[column])
+			individualStrains[column] += 2 * holdFactor
+
+			// chords are as hard as the hardest column in them
+			if d.deltaTime <= 1 {
+				individualStrain = math.Max(individualStrain, individualStrains[column])
+			} else {
+				individualStrain = individualStrains[column]
+			}
+
+			overallStrain *= strainDecay(MANIA_OVERALL_DECAY_BASE, d.deltaTime)
+			overallStrain += (1 + holdAddition) * holdFactor
+
+			startTimes[column] = d.startTime
+			endTimes[column] = d.endTime
+			return individualStrain + overallStrain
+		},
+		initialStrain: func(time float64, i int) float64 {
+			elapsed := time - objects[i-1].startTime
+			return individualStrain*strainDecay(MANIA_INDIVIDUAL_DECAY_BASE, elapsed) + overallStrain*strainDecay(MANIA_OVERALL_DECAY_BASE, elapsed)
+		},
+	}
+}
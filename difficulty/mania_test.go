@@ -0,0 +1,66 @@
+package difficulty
+
+import (
+	"testing"
+
+	osu "github.com/iptq/osu-go"
+)
+
+// maniaStream makes a map that goes through every column in turn, a note
+// every interval milliseconds.
+func maniaStream(keyCount int, interval int, holds bool) *osu.Beatmap {
+	m := &osu.Beatmap{Mode: osu.MODE_MANIA, CircleSize: float64(keyCount), OverallDifficulty: 8}
+	for i := 0; i < 500; i++ {
+		pos := osu.NewIntPoint(osu.ColumnX(i%keyCount, keyCount), 192)
+		start := osu.TimestampAbsolute(1000 + i*interval)
+
+		var obj osu.HitObject = osu.NewCircle(pos, start, false, 0, osu.Extras{})
+		if holds && i%2 == 0 {
+			obj = osu.NewHoldNote(pos, start, osu.TimestampAbsolute(1000+i*interval+3*interval), 0, osu.Extras{})
+		}
+		m.HitObjects = append(m.HitObjects, &obj)
+	}
+	return m
+}
+
+func TestManiaDifficulty(t *testing.T) {
+	if _, err := CalculateMania(loadMap(t, "HanStone - Starlight of Ancient Times (soulfear) [Normal].osu"), 0); err == nil {
+		t.Error("expected an error for an osu!standard map")
+	}
+
+	slow, err := CalculateMania(maniaStream(4, 200, false), 0)
+	if err != nil {
+		t.Fatalf("failed to calculate difficulty: %v", err)
+	}
+	fast, _ := CalculateMania(maniaStream(4, 100, false), 0)
+	if slow.StarRating <= 0 || fast.StarRating <= slow.StarRating {
+		t.Errorf("expected a faster stream to be harder, got %.2f and %.2f", slow.StarRating, fast.StarRating)
+	}
+	expectClose(t, "stream star rating", fast.StarRating, 2.2243, 1e-4)
+	if slow.MaxCombo != 500 || slow.NoteCount != 500 || slow.GreatHitWindow != 40 {
+		t.Errorf("wrong attributes: %+v", slow)
+	}
+
+	holds, _ := CalculateMania(maniaStream(4, 200, true), 0)
+	if holds.StarRating <= slow.StarRating || holds.HoldNoteCount != 250 || holds.MaxCombo != 750 {
+		t.Errorf("expected hold notes to make it harder, got %+v", holds)
+	}
+	fastHolds, _ := CalculateMania(maniaStream(4, 100, true), 0)
+	expectClose(t, "hold note star rating", fastHolds.StarRating, 3.3397, 1e-4)
+
+	dt, _ := CalculateMania(maniaStream(4, 200, false), MOD_DOUBLETIME)
+	if dt.StarRating <= slow.StarRating {
+		t.Errorf("expected DT to be harder, got %.2f", dt.StarRating)
+	}
+
+	// the hit window is scaled by the clock rate twice, like in the game
+	dt, _ = CalculateMania(maniaStream(7, 100, true), MOD_DOUBLETIME)
+	expectClose(t, "DT star rating", dt.StarRating, 4.4461, 1e-4)
+	expectClose(t, "DT great hit window", dt.GreatHitWindow, 60, 1e-4)
+
+	// dual stage maps get every column
+	dual, _ := CalculateMania(maniaStream(14, 100, false), 0)
+	if dual.StarRating <= 0 || dual.StarRating >= fast.StarRating {
+		t.Errorf("expected spreading notes over more columns to be easier, got %.2f", dual.StarRating)
+	}
+}
@@ -0,0 +1,113 @@
+package difficulty
+
+import (
+	osu "github.com/iptq/osu-go"
+)
+
+// objects closer together than this are stacked
+const STACK_DISTANCE = 3.0
+
+func distance(a, b osu.FloatPoint) float64 {
+	return a.Sub(b).Magnitude()
+}
+
+// applyStacking works out how high each object is stacked, which the game
+// uses to move overlapping objects slightly apart.
+func applyStacking(objects []*standardObject, version int, preempt, stackLeniency float64) {
+	if version < 6 {
+		applyStackingOld(objects, preempt, stackLeniency)
+		return
+	}
+
+	stackThreshold := preempt * stackLeniency
+	extendedStartIndex := 0
+
+	// go backwards, so that objects get stacked on top of the ones that
+	// come after them
+	for i := len(objects) - 1; i > 0; i-- {
+		objectI := objects[i]
+		if objectI.stackHeight != 0 || objectI.kind == osu.OBJ_SPINNER {
+			continue
+		}
+
+		switch objectI.kind {
+		case osu.OBJ_CIRCLE:
+			for n := i - 1; n >= 0; n-- {
+				objectN := objects[n]
+				if objectN.kind == osu.OBJ_SPINNER {
+					continue
+				}
+				if objectI.startTime-objectN.endTime > stackThreshold {
+					break
+				}
+
+				if n < extendedStartIndex {
+					objectN.stackHeight = 0
+					extendedStartIndex = n
+				}
+
+				// a circle under the end of a slider pushes everything
+				// after the slider the other way instead
+				if objectN.kind == osu.OBJ_SLIDER && distance(objectN.endPosition, objectI.position) < STACK_DISTANCE {
+					offset := objectI.stackHeight - objectN.stackHeight + 1
+					for j := n + 1; j <= i; j++ {
+						if distance(objectN.endPosition, objects[j].position) < STACK_DISTANCE {
+							objects[j].stackHeight -= offset
+						}
+					}
+					break
+				}
+
+				if distance(objectN.position, objectI.position) < STACK_DISTANCE {
+					objectN.stackHeight = objectI.stackHeight + 1
+					objectI = objectN
+				}
+			}
+		case osu.OBJ_SLIDER:
+			for n := i - 1; n >= 0; n-- {
+				objectN := objects[n]
+				if objectN.kind == osu.OBJ_SPINNER {
+					continue
+				}
+				if objectI.startTime-objectN.startTime > stackThreshold {
+					break
+				}
+
+				if distance(objectN.endPosition, objectI.position) < STACK_DISTANCE {
+					objectN.stackHeight = objectI.stackHeight + 1
+					objectI = objectN
+				}
+			}
+		}
+	}
+}
+
+// applyStackingOld is how stacking worked for maps before v6.
+func applyStackingOld(objects []*standardObject, preempt, stackLeniency float64) {
+	stackThreshold := preempt * stackLeniency
+
+	for i, curr := range objects {
+		if curr.stackHeight != 0 && curr.kind != osu.OBJ_SLIDER {
+			continue
+		}
+
+		startTime := curr.endTime
+		sliderStack := 0
+
+		for j := i + 1; j < len(objects); j++ {
+			if objects[j].startTime-stackThreshold > startTime {
+				break
+			}
+
+			if distance(objects[j].position, curr.position) < STACK_DISTANCE {
+				curr.stackHeight++
+				startTime = objects[j].endTime
+			} else if distance(objects[j].position, curr.pathEndPosition) < STACK_DISTANCE {
+				// sliders stack from their end, in the other direction
+				sliderStack++
+				objects[j].stackHeight -= sliderStack
+				startTime = objects[j].endTime
+			}
+		}
+	}
+}
@@ -0,0 +1,442 @@
+package difficulty
+
+import (
+	"math"
+
+	osu "github.com/iptq/osu-go"
+)
+
+// StandardAttributes describes the difficulty of an osu!standard map.
+type StandardAttributes struct {
+	StarRating float64
+
+	AimDifficulty   float64
+	SpeedDifficulty float64
+
+	// FlashlightDifficulty is only rated with the Flashlight mod, and is 0
+	// otherwise
+	FlashlightDifficulty float64
+
+	// SpeedNoteCount is roughly how many of the objects are hard to tap
+	SpeedNoteCount float64
+
+	// SliderFactor is how much of the aim difficulty is left if sliders are
+	// ignored, from 0 to 1
+	SliderFactor float64
+
+	// the settings after applying mods, including the speed changing ones
+	ApproachRate      float64
+	OverallDifficulty float64
+	HPDrainRate       float64
+
+	MaxCombo     int
+	CircleCount  int
+	SliderCount  int
+	SpinnerCount int
+}
+
+const (
+	STANDARD_DIFFICULTY_MULTIPLIER       = 0.0675
+	STANDARD_PERFORMANCE_BASE_MULTIPLIER = 1.14
+
+	// distances are scaled so that every circle has this radius
+	NORMALISED_RADIUS = 50.0
+
+	// objects closer together in time than this are treated as being this far
+	// apart, so that strain doesn't get out of hand
+	MIN_DELTA_TIME = 25.0
+
+	maximumSliderRadius = NORMALISED_RADIUS * 2.4
+	assumedSliderRadius = NORMALISED_RADIUS * 1.8
+)
+
+// standardObject is a hit object with everything the calculator needs to know
+// about it worked out ahead of time.
+type standardObject struct {
+	kind               osu.HitObjectType
+	startTime, endTime float64
+	position           osu.FloatPoint
+	stackHeight        int
+	stackOffset        osu.FloatPoint
+
+	// endPosition is where the object ends up, and pathEndPosition is the end
+	// of the slider path regardless of the number of slides
+	endPosition     osu.FloatPoint
+	pathEndPosition osu.FloatPoint
+
+	// sliders only
+	slider       osu.ObjSlider
+	nested       []osu.SliderEvent
+	spanDuration float64
+	repeatCount  int
+
+	// the path the cursor takes through a slider if it does as little moving
+	// as possible, worked out when it's first needed
+	lazyDone           bool
+	lazyEndPosition    osu.FloatPoint
+	lazyTravelDistance float64
+	lazyTravelTime     float64
+}
+
+func (obj *standardObject) stackedPosition() osu.FloatPoint {
+	return obj.position.Add(obj.stackOffset)
+}
+
+// opacityAt returns how visible the object is at the given time, from 0 to 1,
+// ignoring the clock rate. Objects are treated as invisible once they start.
+func (obj *standardObject) opacityAt(time float64, hidden bool, preempt float64) float64 {
+	if time > obj.startTime {
+		return 0
+	}
+
+	fadeInStartTime := obj.startTime - preempt
+	fadeInDuration := 400 * math.Min(1, preempt/450)
+	opacity := clamp((time-fadeInStartTime)/fadeInDuration, 0, 1)
+	if hidden {
+		fadeOutStartTime := fadeInStartTime + fadeInDuration
+		fadeOutDuration := preempt * HIDDEN_FADE_OUT_DURATION_MULTIPLIER
+		opacity = math.Min(opacity, 1-clamp((time-fadeOutStartTime)/fadeOutDuration, 0, 1))
+	}
+	return opacity
+}
+
+// standardObjects converts the map's hit objects, and stacks them.
+func standardObjects(m *osu.Beatmap, preempt, scale float64) []*standardObject {
+	var objects []*standardObject
+	for _, hitObject := range m.HitObjects {
+		obj := &standardObject{
+			kind:      (*hitObject).GetType(),
+			startTime: float64((*hitObject).GetStartTime().Milliseconds()),
+			position:  (*hitObject).GetPosition().ToFloat(),
+		}
+		obj.endTime = obj.startTime
+		obj.endPosition = obj.position
+		obj.pathEndPosition = obj.position
+
+		switch o := (*hitObject).(type) {
+		case osu.ObjSlider:
+			timing := m.SliderTiming(o)
+			obj.slider = o
+			obj.endTime = o.EndTime(timing)
+			obj.spanDuration = timing.SpanDuration
+			obj.repeatCount = o.Repeats()
+			obj.endPosition = o.PositionAt(float64(o.Slides() % 2))
+			obj.pathEndPosition = o.EndPosition()
+
+			// the legacy last tick takes the place of the tail
+			for _, ev := range o.Events(timing) {
+				if ev.Kind != osu.SLIDER_TAIL {
+					obj.nested = append(obj.nested, ev)
+				}
+			}
+		case osu.ObjSpinner:
+			obj.endTime = float64(o.GetEndTime().Milliseconds())
+		case osu.ObjHoldNote:
+			continue
+		}
+
+		objects = append(objects, obj)
+	}
+
+	applyStacking(objects, m.Version, preempt, m.StackLeniency)
+	for _, obj := range objects {
+		offset := float64(obj.stackHeight) * scale * -6.4
+		obj.stackOffset = osu.NewFloatPoint(offset, offset)
+	}
+	return objects
+}
+
+// standardDifficultyObject describes how an object is played, relative to
+// the ones before it.
+type standardDifficultyObject struct {
+	obj   *standardObject
+	index int
+
+	// times are adjusted for the clock rate
+	startTime  float64
+	deltaTime  float64
+	strainTime float64
+
+	// hitWindowGreat is the full width of the 300 window
+	hitWindowGreat float64
+
+	lazyJumpDistance    float64
+	minimumJumpDistance float64
+	minimumJumpTime     float64
+	travelDistance      float64
+	travelTime          float64
+
+	// angle is the angle between this object and the two before it, if there
+	// are two before it that aren't spinners
+	angle    float64
+	hasAngle bool
+}
+
+func newStandardDifficultyObject(curr, last, lastLast *standardObject, index int, clockRate, radius, hitWindowGreat float64) *standardDifficultyObject {
+	d := &standardDifficultyObject{
+		obj:       curr,
+		index:     index,
+		startTime: curr.startTime / clockRate,
+		deltaTime: (curr.startTime - last.startTime) / clockRate,
+	}
+	d.strainTime = math.Max(d.deltaTime, MIN_DELTA_TIME)
+	d.hitWindowGreat = 2 * hitWindowGreat / clockRate
+
+	if curr.kind == osu.OBJ_SLIDER {
+		computeSliderCursorPosition(curr, radius)
+		d.travelDistance = curr.lazyTravelDistance * math.Pow(1+float64(curr.repeatCount)/2.5, 1/2.5)
+		d.travelTime = math.Max(curr.lazyTravelTime/clockRate, MIN_DELTA_TIME)
+	}
+
+	if curr.kind == osu.OBJ_SPINNER || last.kind == osu.OBJ_SPINNER {
+		return d
+	}
+
+	// small circles are harder to aim at than the distance would suggest
+	scalingFactor := NORMALISED_RADIUS / radius
+	if radius < 30 {
+		smallCircleBonus := math.Min(30-radius, 5) / 50
+		scalingFactor *= 1 + smallCircleBonus
+	}
+
+	lastCursorPosition := endCursorPosition(last, radius)
+	d.lazyJumpDistance = curr.stackedPosition().ScalarMul(scalingFactor).Sub(lastCursorPosition.ScalarMul(scalingFactor)).Magnitude()
+	d.minimumJumpTime = d.strainTime
+	d.minimumJumpDistance = d.lazyJumpDistance
+
+	if last.kind == osu.OBJ_SLIDER {
+		lastTravelTime := math.Max(last.lazyTravelTime/clockRate, MIN_DELTA_TIME)
+		d.minimumJumpTime = math.Max(d.strainTime-lastTravelTime, MIN_DELTA_TIME)
+
+		// the cursor doesn't have to go all the way to the end of the
+		// slider, so the jump could be shorter than it looks
+		tail := last.nested[len(last.nested)-1].Position.Add(last.stackOffset)
+		tailJumpDistance := tail.Sub(curr.stackedPosition()).Magnitude() * scalingFactor
+		d.minimumJumpDistance = math.Max(0, math.Min(
+			d.lazyJumpDistance-(maximumSliderRadius-assumedSliderRadius),
+			tailJumpDistance-maximumSliderRadius,
+		))
+	}
+
+	if lastLast != nil && lastLast.kind != osu.OBJ_SPINNER {
+		lastLastCursorPosition := endCursorPosition(lastLast, radius)
+
+		v1 := lastLastCursorPosition.Sub(last.stackedPosition())
+		v2 := curr.stackedPosition().Sub(lastCursorPosition)
+		dot := v1.X()*v2.X() + v1.Y()*v2.Y()
+		det := v1.X()*v2.Y() - v1.Y()*v2.X()
+
+		d.angle = math.Abs(math.Atan2(det, dot))
+		d.hasAngle = true
+	}
+
+	return d
+}
+
+// computeSliderCursorPosition works out the shortest path the cursor can take
+// while still following the slider.
+func computeSliderCursorPosition(slider *standardObject, radius float64) {
+	if slider.lazyDone {
+		return
+	}
+	slider.lazyDone = true
+
+	last := slider.nested[len(slider.nested)-1]
+	slider.lazyTravelTime = last.Time - slider.startTime
+
+	// where the slider is when it stops being tracked
+	endTimeMin := 0.0
+	if slider.spanDuration > 0 {
+		endTimeMin = slider.lazyTravelTime / slider.spanDuration
+	}
+	if math.Mod(endTimeMin, 2) >= 1 {
+		endTimeMin = 1 - math.Mod(endTimeMin, 1)
+	} else {
+		endTimeMin = math.Mod(endTimeMin, 1)
+	}
+	slider.lazyEndPosition = slider.slider.PositionAt(endTimeMin).Add(slider.stackOffset)
+
+	currCursorPosition := slider.stackedPosition()
+	scalingFactor := NORMALISED_RADIUS / radius
+
+	for i := 1; i < len(slider.nested); i++ {
+		nested := slider.nested[i]
+		currMovement := nested.Position.Add(slider.stackOffset).Sub(currCursorPosition)
+		currMovementLength := scalingFactor * currMovement.Magnitude()
+
+		// the cursor only has to stay within the follow circle
+		requiredMovement := assumedSliderRadius
+
+		if i == len(slider.nested)-1 {
+			// the end of the slider doesn't need to be reached, as long as
+			// it's close enough
+			lazyMovement := slider.lazyEndPosition.Sub(currCursorPosition)
+			if lazyMovement.Magnitude() < currMovement.Magnitude() {
+				currMovement = lazyMovement
+			}
+			currMovementLength = scalingFactor * currMovement.Magnitude()
+		} else if nested.Kind == osu.SLIDER_REPEAT {
+			// repeats have to be hit more precisely
+			requiredMovement = NORMALISED_RADIUS
+		}
+
+		if currMovementLength > requiredMovement {
+			ratio := (currMovementLength - requiredMovement) / currMovementLength
+			currCursorPosition = currCursorPosition.Add(currMovement.ScalarMul(ratio))
+			currMovementLength *= ratio
+			slider.lazyTravelDistance += currMovementLength
+		}
+
+		if i == len(slider.nested)-1 {
+			slider.lazyEndPosition = currCursorPosition
+		}
+	}
+}
+
+func endCursorPosition(obj *standardObject, radius float64) osu.FloatPoint {
+	if obj.kind == osu.OBJ_SLIDER {
+		computeSliderCursorPosition(obj, radius)
+		return obj.lazyEndPosition
+	}
+	return obj.stackedPosition()
+}
+
+// CalculateStandard calculates the difficulty of an osu!standard map.
+func CalculateStandard(m *osu.Beatmap, mods Mods) StandardAttributes {
+	settings := AdjustedSettings(m, mods)
+	clockRate := ClockRate(mods)
+
+	preempt := osu.DifficultyRange(settings.ApproachRate, 1800, 1200, 450)
+	hitWindowGreat := osu.DifficultyRange(settings.OverallDifficulty, 80, 50, 20)
+	scale := (1 - 0.7*(settings.CircleSize-5)/5) / 2
+	radius := 64 * scale
+
+	objects := standardObjects(m, preempt, scale)
+
+	attrs := StandardAttributes{
+		HPDrainRate:       settings.HPDrainRate,
+		ApproachRate:      approachRateFromPreempt(preempt / clockRate),
+		OverallDifficulty: (80 - hitWindowGreat/clockRate) / 6,
+	}
+	for _, obj := range objects {
+		switch obj.kind {
+		case osu.OBJ_CIRCLE:
+			attrs.CircleCount++
+			attrs.MaxCombo++
+		case osu.OBJ_SLIDER:
+			attrs.SliderCount++
+			attrs.MaxCombo += len(obj.nested)
+		case osu.OBJ_SPINNER:
+			attrs.SpinnerCount++
+			attrs.MaxCombo++
+		}
+	}
+
+	if len(objects) == 0 {
+		return attrs
+	}
+
+	var diffObjects []*standardDifficultyObject
+	for i := 1; i < len(objects); i++ {
+		var lastLast *standardObject
+		if i > 1 {
+			lastLast = objects[i-2]
+		}
+		diffObjects = append(diffObjects, newStandardDifficultyObject(objects[i], objects[i-1], lastLast, len(diffObjects), clockRate, radius, hitWindowGreat))
+	}
+
+	aim := newAimSkill(diffObjects, true)
+	aimNoSliders := newAimSkill(diffObjects, false)
+	speed := newSpeedSkill(diffObjects)
+	var flashlight *strainSkill
+	if mods&MOD_FLASHLIGHT != 0 {
+		flashlight = newFlashlightSkill(diffObjects, mods&MOD_HIDDEN != 0, radius, preempt)
+	}
+	for i, d := range diffObjects {
+		aim.process(i, d.startTime)
+		aimNoSliders.process(i, d.startTime)
+		speed.process(i, d.startTime)
+		if flashlight != nil {
+			flashlight.process(i, d.startTime)
+		}
+	}
+
+	aimRating := math.Sqrt(standardDifficultyValue(aim.strainPeaks(), 10, 1.06)) * STANDARD_DIFFICULTY_MULTIPLIER
+	aimRatingNoSliders := math.Sqrt(standardDifficultyValue(aimNoSliders.strainPeaks(), 10, 1.06)) * STANDARD_DIFFICULTY_MULTIPLIER
+	speedRating := math.Sqrt(standardDifficultyValue(speed.strainPeaks(), 5, 1.04)) * STANDARD_DIFFICULTY_MULTIPLIER
+	flashlightRating := 0.0
+	if flashlight != nil {
+		total := 0.0
+		for _, peak := range flashlight.strainPeaks() {
+			total += peak
+		}
+		flashlightRating = math.Sqrt(total*1.06) * STANDARD_DIFFICULTY_MULTIPLIER
+	}
+
+	attrs.SliderFactor = 1
+	if aimRating > 0 {
+		attrs.SliderFactor = aimRatingNoSliders / aimRating
+	}
+
+	if mods&MOD_RELAX != 0 {
+		aimRating *= 0.9
+		speedRating = 0
+		flashlightRating *= 0.7
+	}
+
+	attrs.AimDifficulty = aimRating
+	attrs.SpeedDifficulty = speedRating
+	attrs.FlashlightDifficulty = flashlightRating
+	attrs.SpeedNoteCount = speed.relevantNoteCount()
+
+	baseAimPerformance := StandardStrainToPerformance(aimRating)
+	baseSpeedPerformance := StandardStrainToPerformance(speedRating)
+	baseFlashlightPerformance := FlashlightStrainToPerformance(flashlightRating)
+	basePerformance := math.Pow(math.Pow(baseAimPerformance, 1.1)+math.Pow(baseSpeedPerformance, 1.1)+math.Pow(baseFlashlightPerformance, 1.1), 1/1.1)
+	if basePerformance > 0.00001 {
+		attrs.StarRating = math.Cbrt(STANDARD_PERFORMANCE_BASE_MULTIPLIER) * 0.027 * (math.Cbrt(100000/math.Pow(2, 1/1.1)*basePerformance) + 4)
+	}
+
+	return attrs
+}
+
+// StandardStrainToPerformance converts an aim or speed rating into the
+// performance it would be worth on its own.
+func StandardStrainToPerformance(rating float64) float64 {
+	return math.Pow(5*math.Max(1, rating/STANDARD_DIFFICULTY_MULTIPLIER)-4, 3) / 100000
+}
+
+// FlashlightStrainToPerformance converts a flashlight rating into the
+// performance it would be worth on its own.
+func FlashlightStrainToPerformance(rating float64) float64 {
+	return 25 * rating * rating
+}
+
+func approachRateFromPreempt(preempt float64) float64 {
+	if preempt > 1200 {
+		return (1800 - preempt) / 120
+	}
+	return (1200-preempt)/150 + 5
+}
+
+// standardDifficultyValue turns strain peaks into a difficulty, giving less
+// weight to the hardest few sections so that a single hard part of a map
+// doesn't count for too much.
+func standardDifficultyValue(peaks []float64, reducedSectionCount int, multiplier float64) float64 {
+	const reducedStrainBaseline = 0.75
+
+	var strains []float64
+	for _, p := range peaks {
+		if p > 0 {
+			strains = append(strains, p)
+		}
+	}
+	sortDescending(strains)
+
+	for i := 0; i < len(strains) && i < reducedSectionCount; i++ {
+		scale := math.Log10(lerp(1, 10, clamp(float64(i)/float64(reducedSectionCount), 0, 1)))
+		strains[i] *= lerp(reducedStrainBaseline, 1, scale)
+	}
+
+	return weightedSum(strains, 0.9) * multiplier
+}
@@ -0,0 +1,391 @@
+package difficulty
+
+import (
+	"math"
+
+	osu "github.com/iptq/osu-go"
+)
+
+const (
+	AIM_SKILL_MULTIPLIER   = 23.55
+	AIM_STRAIN_DECAY_BASE  = 0.15
+	AIM_WIDE_ANGLE_MULT    = 1.5
+	AIM_ACUTE_ANGLE_MULT   = 1.95
+	AIM_SLIDER_MULT        = 1.35
+	AIM_VELOCITY_CHANGE    = 0.75
+	SPEED_SKILL_MULTIPLIER = 1375.0
+	SPEED_STRAIN_DECAY     = 0.3
+
+	// jumps shorter than this are treated as streams
+	SPEED_SINGLE_SPACING_THRESHOLD = 125.0
+
+	// notes closer together than this, ~200BPM 1/4, get a bonus
+	SPEED_MIN_BONUS_TIME    = 75.0
+	SPEED_BALANCING_FACTOR  = 40.0
+	RHYTHM_HISTORY_TIME_MAX = 5000.0
+	RHYTHM_MULTIPLIER       = 0.75
+	RHYTHM_HISTORY_OBJECTS  = 32
+
+	FLASHLIGHT_SKILL_MULTIPLIER  = 0.052
+	FLASHLIGHT_STRAIN_DECAY_BASE = 0.15
+	FLASHLIGHT_HISTORY_OBJECTS   = 10
+	FLASHLIGHT_MAX_OPACITY_BONUS = 0.4
+	FLASHLIGHT_HIDDEN_BONUS      = 0.2
+	FLASHLIGHT_MIN_VELOCITY      = 0.5
+	FLASHLIGHT_SLIDER_MULT       = 1.3
+	FLASHLIGHT_MIN_ANGLE_MULT    = 0.2
+
+	// Hidden fades objects out over this fraction of the preempt time
+	HIDDEN_FADE_OUT_DURATION_MULTIPLIER = 0.3
+)
+
+// previous returns the object i+1 places before d, or nil if there isn't one.
+func previous(objects []*standardDifficultyObject, d *standardDifficultyObject, i int) *standardDifficultyObject {
+	if j := d.index - (i + 1); j >= 0 {
+		return objects[j]
+	}
+	return nil
+}
+
+func newAimSkill(objects []*standardDifficultyObject, withSliders bool) *strainSkill {
+	currentStrain := 0.0
+	return &strainSkill{
+		strainValueAt: func(i int) float64 {
+			currentStrain *= strainDecay(AIM_STRAIN_DECAY_BASE, objects[i].deltaTime)
+			currentStrain += evaluateAim(objects, objects[i], withSliders) * AIM_SKILL_MULTIPLIER
+			return currentStrain
+		},
+		initialStrain: func(time float64, i int) float64 {
+			return currentStrain * strainDecay(AIM_STRAIN_DECAY_BASE, time-objects[i-1].startTime)
+		},
+	}
+}
+
+func wideAngleBonus(angle float64) float64 {
+	return math.Pow(math.Sin(3.0/4*(clamp(angle, math.Pi/6, 5.0/6*math.Pi)-math.Pi/6)), 2)
+}
+
+func acuteAngleBonus(angle float64) float64 {
+	return 1 - wideAngleBonus(angle)
+}
+
+// evaluateAim works out how hard it is to move the cursor to an object.
+func evaluateAim(objects []*standardDifficultyObject, curr *standardDifficultyObject, withSliders bool) float64 {
+	if curr.obj.kind == osu.OBJ_SPINNER || curr.index <= 1 || previous(objects, curr, 0).obj.kind == osu.OBJ_SPINNER {
+		return 0
+	}
+
+	last := previous(objects, curr, 0)
+	lastLast := previous(objects, curr, 1)
+
+	// sliders count as moving for as long as the cursor has to follow them
+	currVelocity := curr.lazyJumpDistance / curr.strainTime
+	if last.obj.kind == osu.OBJ_SLIDER && withSliders {
+		travelVelocity := last.travelDistance / last.travelTime
+		movementVelocity := curr.minimumJumpDistance / curr.minimumJumpTime
+		currVelocity = math.Max(currVelocity, movementVelocity+travelVelocity)
+	}
+
+	prevVelocity := last.lazyJumpDistance / last.strainTime
+	if lastLast.obj.kind == osu.OBJ_SLIDER && withSliders {
+		travelVelocity := lastLast.travelDistance / lastLast.travelTime
+		movementVelocity := last.minimumJumpDistance / last.minimumJumpTime
+		prevVelocity = math.Max(prevVelocity, movementVelocity+travelVelocity)
+	}
+
+	wideBonus, acuteBonus, sliderBonus, velocityChangeBonus := 0.0, 0.0, 0.0, 0.0
+	aimStrain := currVelocity
+
+	// angles only matter if the rhythm stays about the same
+	if math.Max(curr.strainTime, last.strainTime) < 1.25*math.Min(curr.strainTime, last.strainTime) {
+		if curr.hasAngle && last.hasAngle && lastLast.hasAngle {
+			angleBonus := math.Min(currVelocity, prevVelocity)
+
+			wideBonus = wideAngleBonus(curr.angle)
+			acuteBonus = acuteAngleBonus(curr.angle)
+
+			if curr.strainTime > 100 {
+				// acute angles are only hard at high BPM
+				acuteBonus = 0
+			} else {
+				acuteBonus *= acuteAngleBonus(last.angle) *
+					math.Min(angleBonus, 125/curr.strainTime) *
+					math.Pow(math.Sin(math.Pi/2*math.Min(1, (100-curr.strainTime)/25)), 2) *
+					math.Pow(math.Sin(math.Pi/2*(clamp(curr.lazyJumpDistance, 50, 100)-50)/50), 2)
+			}
+
+			// don't reward repeating the same angle over and over
+			wideBonus *= angleBonus * (1 - math.Min(wideBonus, math.Pow(wideAngleBonus(last.angle), 3)))
+			acuteBonus *= 0.5 + 0.5*(1-math.Min(acuteBonus, math.Pow(acuteAngleBonus(lastLast.angle), 3)))
+		}
+	}
+
+	if math.Max(prevVelocity, currVelocity) != 0 {
+		// include the slider travel, so that going from a slider to a jump
+		// isn't counted as a change in speed
+		prevVelocity = (last.lazyJumpDistance + lastLast.travelDistance) / last.strainTime
+		currVelocity = (curr.lazyJumpDistance + last.travelDistance) / curr.strainTime
+
+		distRatio := math.Pow(math.Sin(math.Pi/2*math.Abs(prevVelocity-currVelocity)/math.Max(prevVelocity, currVelocity)), 2)
+		overlapVelocityBuff := math.Min(125/math.Min(curr.strainTime, last.strainTime), math.Abs(prevVelocity-currVelocity))
+		velocityChangeBonus = overlapVelocityBuff * distRatio
+		velocityChangeBonus *= math.Pow(math.Min(curr.strainTime, last.strainTime)/math.Max(curr.strainTime, last.strainTime), 2)
+	}
+
+	if last.obj.kind == osu.OBJ_SLIDER {
+		sliderBonus = last.travelDistance / last.travelTime
+	}
+
+	aimStrain += math.Max(acuteBonus*AIM_ACUTE_ANGLE_MULT, wideBonus*AIM_WIDE_ANGLE_MULT+velocityChangeBonus*AIM_VELOCITY_CHANGE)
+	if withSliders {
+		aimStrain += sliderBonus * AIM_SLIDER_MULT
+	}
+	return aimStrain
+}
+
+type speedSkill struct {
+	strainSkill
+	objectStrains []float64
+}
+
+func newSpeedSkill(objects []*standardDifficultyObject) *speedSkill {
+	s := &speedSkill{}
+	currentStrain, currentRhythm := 0.0, 0.0
+	s.strainValueAt = func(i int) float64 {
+		currentStrain *= strainDecay(SPEED_STRAIN_DECAY, objects[i].strainTime)
+		currentStrain += evaluateSpeed(objects, objects[i]) * SPEED_SKILL_MULTIPLIER
+		currentRhythm = evaluateRhythm(objects, objects[i])
+
+		totalStrain := currentStrain * currentRhythm
+		s.objectStrains = append(s.objectStrains, totalStrain)
+		return totalStrain
+	}
+	s.initialStrain = func(time float64, i int) float64 {
+		return currentStrain * currentRhythm * strainDecay(SPEED_STRAIN_DECAY, time-objects[i-1].startTime)
+	}
+	return s
+}
+
+// relevantNoteCount counts the objects, weighted by how close their strain is
+// to the hardest one.
+func (s *speedSkill) relevantNoteCount() float64 {
+	maxStrain := 0.0
+	for _, strain := range s.objectStrains {
+		maxStrain = math.Max(maxStrain, strain)
+	}
+	if maxStrain == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, strain := range s.objectStrains {
+		total += 1 / (1 + math.Exp(-(strain/maxStrain*12 - 6)))
+	}
+	return total
+}
+
+// evaluateSpeed works out how hard it is to tap an object in time.
+func evaluateSpeed(objects []*standardDifficultyObject, curr *standardDifficultyObject) float64 {
+	if curr.obj.kind == osu.OBJ_SPINNER {
+		return 0
+	}
+
+	prev := previous(objects, curr, 0)
+	strainTime := curr.strainTime
+
+	// notes that can be hit as a single press with the one after it aren't
+	// really hard to tap
+	doubletapness := 1.0
+	if curr.index+1 < len(objects) {
+		next := objects[curr.index+1]
+		currDeltaTime := math.Max(1, curr.deltaTime)
+		nextDeltaTime := math.Max(1, next.deltaTime)
+		deltaDifference := math.Abs(nextDeltaTime - currDeltaTime)
+		speedRatio := currDeltaTime / math.Max(currDeltaTime, deltaDifference)
+		windowRatio := math.Pow(math.Min(1, currDeltaTime/curr.hitWindowGreat), 2)
+		doubletapness = math.Pow(speedRatio, 1-windowRatio)
+	}
+
+	// cap the speed at what the hit window allows
+	strainTime /= clamp((strainTime/curr.hitWindowGreat)/0.93, 0.92, 1)
+
+	speedBonus := 1.0
+	if strainTime < SPEED_MIN_BONUS_TIME {
+		speedBonus = 1 + 0.75*math.Pow((SPEED_MIN_BONUS_TIME-strainTime)/SPEED_BALANCING_FACTOR, 2)
+	}
+
+	travelDistance := 0.0
+	if prev != nil {
+		travelDistance = prev.travelDistance
+	}
+	distance := math.Min(SPEED_SINGLE_SPACING_THRESHOLD, travelDistance+curr.minimumJumpDistance)
+
+	return (speedBonus + speedBonus*math.Pow(distance/SPEED_SINGLE_SPACING_THRESHOLD, 3.5)) * doubletapness / strainTime
+}
+
+// evaluateRhythm works out how complicated the rhythm leading up to an object
+// is, as a multiplier for speed strain.
+func evaluateRhythm(objects []*standardDifficultyObject, curr *standardDifficultyObject) float64 {
+	if curr.obj.kind == osu.OBJ_SPINNER {
+		return 0
+	}
+
+	previousIslandSize := 0
+	rhythmComplexitySum := 0.0
+	islandSize := 1
+	startRatio := 0.0
+	firstDeltaSwitch := false
+
+	historicalNoteCount := curr.index
+	if historicalNoteCount > RHYTHM_HISTORY_OBJECTS {
+		historicalNoteCount = RHYTHM_HISTORY_OBJECTS
+	}
+
+	rhythmStart := 0
+	for rhythmStart < historicalNoteCount-2 && curr.startTime-previous(objects, curr, rhythmStart).startTime < RHYTHM_HISTORY_TIME_MAX {
+		rhythmStart++
+	}
+
+	for i := rhythmStart; i > 0; i-- {
+		currObj := previous(objects, curr, i-1)
+		prevObj := previous(objects, curr, i)
+		lastObj := previous(objects, curr, i+1)
+
+		// older notes count for less
+		currHistoricalDecay := (RHYTHM_HISTORY_TIME_MAX - (curr.startTime - currObj.startTime)) / RHYTHM_HISTORY_TIME_MAX
+		currHistoricalDecay = math.Min(float64(historicalNoteCount-i)/float64(historicalNoteCount), currHistoricalDecay)
+
+		currDelta := currObj.strainTime
+		prevDelta := prevObj.strainTime
+		lastDelta := lastObj.strainTime
+
+		currRatio := 1 + 6*math.Min(0.5, math.Pow(math.Sin(math.Pi/(math.Min(prevDelta, currDelta)/math.Max(prevDelta, currDelta))), 2))
+
+		// changes smaller than the hit window don't really count
+		windowPenalty := math.Min(1, math.Max(0, math.Abs(prevDelta-currDelta)-currObj.hitWindowGreat*0.3)/(currObj.hitWindowGreat*0.3))
+
+		effectiveRatio := windowPenalty * currRatio
+
+		if firstDeltaSwitch {
+			if !(prevDelta > 1.25*currDelta || prevDelta*1.25 < currDelta) {
+				// still in the same island of evenly spaced notes
+				if islandSize < 7 {
+					islandSize++
+				}
+			} else {
+				if currObj.obj.kind == osu.OBJ_SLIDER {
+					effectiveRatio *= 0.125
+				}
+				if prevObj.obj.kind == osu.OBJ_SLIDER {
+					effectiveRatio *= 0.25
+				}
+				if previousIslandSize == islandSize {
+					effectiveRatio *= 0.25
+				}
+				if previousIslandSize%2 == islandSize%2 {
+					effectiveRatio *= 0.5
+				}
+				if lastDelta > prevDelta+10 && prevDelta > currDelta+10 {
+					// the rhythm is just speeding up
+					effectiveRatio *= 0.125
+				}
+
+				rhythmComplexitySum += math.Sqrt(effectiveRatio*startRatio) * currHistoricalDecay * math.Sqrt(4+float64(islandSize)) / 2 * math.Sqrt(4+float64(previousIslandSize)) / 2
+
+				startRatio = effectiveRatio
+				previousIslandSize = islandSize
+				if prevDelta*1.25 < currDelta {
+					firstDeltaSwitch = false
+				}
+				islandSize = 1
+			}
+		} else if prevDelta > 1.25*currDelta {
+			// the rhythm just got faster, so start looking for changes
+			firstDeltaSwitch = true
+			startRatio = effectiveRatio
+			islandSize = 1
+		}
+	}
+
+	return math.Sqrt(4+rhythmComplexitySum*RHYTHM_MULTIPLIER) / 2
+}
+
+// newFlashlightSkill rates how hard a map is to play when only the area
+// around the cursor can be seen. Unlike the other skills, the strain peaks
+// are added up rather than weighted.
+func newFlashlightSkill(objects []*standardDifficultyObject, hidden bool, radius, preempt float64) *strainSkill {
+	currentStrain := 0.0
+	return &strainSkill{
+		strainValueAt: func(i int) float64 {
+			currentStrain *= strainDecay(FLASHLIGHT_STRAIN_DECAY_BASE, objects[i].deltaTime)
+			currentStrain += evaluateFlashlight(objects, objects[i], hidden, radius, preempt) * FLASHLIGHT_SKILL_MULTIPLIER
+			return currentStrain
+		},
+		initialStrain: func(time float64, i int) float64 {
+			return currentStrain * strainDecay(FLASHLIGHT_STRAIN_DECAY_BASE, time-objects[i-1].startTime)
+		},
+	}
+}
+
+// evaluateFlashlight works out how hard an object is to find and remember,
+// based on how far away the objects before it are and how visible they were.
+func evaluateFlashlight(objects []*standardDifficultyObject, curr *standardDifficultyObject, hidden bool, radius, preempt float64) float64 {
+	if curr.obj.kind == osu.OBJ_SPINNER {
+		return 0
+	}
+
+	scalingFactor := 52 / radius
+	smallDistNerf := 1.0
+	cumulativeStrainTime := 0.0
+	result := 0.0
+	angleRepeatCount := 0.0
+
+	last := curr
+	for i := 0; i < curr.index && i < FLASHLIGHT_HISTORY_OBJECTS; i++ {
+		prev := previous(objects, curr, i)
+		if prev.obj.kind != osu.OBJ_SPINNER {
+			jumpDistance := curr.obj.stackedPosition().Sub(prev.obj.endPosition.Add(prev.obj.stackOffset)).Magnitude()
+			cumulativeStrainTime += last.strainTime
+
+			// objects that can be seen inside the flashlight are easier
+			if i == 0 {
+				smallDistNerf = math.Min(1, jumpDistance/75)
+			}
+
+			// only the first object of a stack counts
+			stackNerf := math.Min(1, prev.lazyJumpDistance/scalingFactor/25)
+
+			opacityBonus := 1 + FLASHLIGHT_MAX_OPACITY_BONUS*(1-curr.obj.opacityAt(prev.obj.startTime, hidden, preempt))
+			result += stackNerf * opacityBonus * scalingFactor * jumpDistance / cumulativeStrainTime
+
+			// objects further back count less towards repeated angles
+			if prev.hasAngle && curr.hasAngle && math.Abs(prev.angle-curr.angle) < 0.02 {
+				angleRepeatCount += math.Max(1-0.1*float64(i), 0)
+			}
+		}
+		last = prev
+	}
+
+	result = math.Pow(smallDistNerf*result, 2)
+
+	// there are no approach circles with Hidden
+	if hidden {
+		result *= 1 + FLASHLIGHT_HIDDEN_BONUS
+	}
+
+	result *= FLASHLIGHT_MIN_ANGLE_MULT + (1-FLASHLIGHT_MIN_ANGLE_MULT)/(angleRepeatCount+1)
+
+	// fast and long sliders take more memorising, but less if they go back
+	// over the same path
+	if curr.obj.kind == osu.OBJ_SLIDER {
+		pixelTravelDistance := curr.obj.lazyTravelDistance / scalingFactor
+		sliderBonus := math.Sqrt(math.Max(0, pixelTravelDistance/curr.travelTime-FLASHLIGHT_MIN_VELOCITY))
+		sliderBonus *= pixelTravelDistance
+		if curr.obj.repeatCount > 0 {
+			sliderBonus /= float64(curr.obj.repeatCount + 1)
+		}
+		result += sliderBonus * FLASHLIGHT_SLIDER_MULT
+	}
+
+	return result
+}
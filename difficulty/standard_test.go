@@ -0,0 +1,116 @@
+package difficulty
+
+import (
+	"strings"
+	"testing"
+
+	osu "github.com/iptq/osu-go"
+)
+
+func TestStandardDifficulty(t *testing.T) {
+	// difficulties from the same set, easiest first
+	names := []string{
+		"HanStone - Starlight of Ancient Times (soulfear) [Normal].osu",
+		"HanStone - Starlight of Ancient Times (soulfear) [Hard].osu",
+		"HanStone - Starlight of Ancient Times (soulfear) [Insane].osu",
+	}
+
+	last := 0.0
+	for _, name := range names {
+		attrs := CalculateStandard(loadMap(t, name), 0)
+		if attrs.StarRating <= last {
+			t.Errorf("%s: expected more than %.2f stars, got %.2f", name, last, attrs.StarRating)
+		}
+		last = attrs.StarRating
+	}
+
+	m := loadMap(t, "Tanaka Aimi - KakushintekiMetamorphose! (deadcode) [IT'S SHOWTIME!!!!!].osu")
+	nomod := CalculateStandard(m, 0)
+	if nomod.StarRating < 7 || nomod.StarRating > 9 {
+		t.Errorf("expected around 8 stars, got %.2f", nomod.StarRating)
+	}
+	if nomod.AimDifficulty <= nomod.SpeedDifficulty {
+		t.Errorf("expected an aim map, got aim %.2f and speed %.2f", nomod.AimDifficulty, nomod.SpeedDifficulty)
+	}
+	if nomod.CircleCount+nomod.SliderCount+nomod.SpinnerCount != len(m.HitObjects) {
+		t.Errorf("object counts don't add up: %+v", nomod)
+	}
+	if nomod.MaxCombo < len(m.HitObjects) || nomod.SliderFactor <= 0 || nomod.SliderFactor > 1 {
+		t.Errorf("wrong attributes: %+v", nomod)
+	}
+	expectClose(t, "star rating", nomod.StarRating, 8.0339, 1e-4)
+	expectClose(t, "aim", nomod.AimDifficulty, 4.3549, 1e-4)
+	expectClose(t, "speed", nomod.SpeedDifficulty, 3.0965, 1e-4)
+	expectClose(t, "speed note count", nomod.SpeedNoteCount, 204.05, 1e-4)
+	expectClose(t, "slider factor", nomod.SliderFactor, 0.99784, 1e-4)
+
+	dt := CalculateStandard(m, MOD_DOUBLETIME)
+	ht := CalculateStandard(m, MOD_HALFTIME)
+	hr := CalculateStandard(m, MOD_HARDROCK)
+	if !(ht.StarRating < nomod.StarRating && nomod.StarRating < dt.StarRating && nomod.StarRating < hr.StarRating) {
+		t.Errorf("mods don't change difficulty as expected: HT %.2f, NM %.2f, DT %.2f, HR %.2f", ht.StarRating, nomod.StarRating, dt.StarRating, hr.StarRating)
+	}
+	if dt.ApproachRate <= nomod.ApproachRate || dt.OverallDifficulty <= nomod.OverallDifficulty {
+		t.Errorf("expected DT to raise AR and OD, got %+v", dt)
+	}
+
+	hddt := CalculateStandard(m, MOD_HIDDEN|MOD_DOUBLETIME)
+	expectClose(t, "HDDT star rating", hddt.StarRating, 12.174, 1e-4)
+	expectClose(t, "HDDT approach rate", hddt.ApproachRate, 11, 1e-4)
+	expectClose(t, "HDDT overall difficulty", hddt.OverallDifficulty, 10.578, 1e-4)
+}
+
+func TestStandardEmpty(t *testing.T) {
+	m, _ := osu.ParseBeatmap(strings.NewReader("osu file format v14\n"))
+	if attrs := CalculateStandard(m, 0); attrs.StarRating != 0 || attrs.MaxCombo != 0 {
+		t.Errorf("expected empty map to have no difficulty, got %+v", attrs)
+	}
+}
+
+func TestStacking(t *testing.T) {
+	m, _ := osu.ParseBeatmap(strings.NewReader(`osu file format v14
+
+[General]
+StackLeniency: 0.7
+
+[HitObjects]
+100,100,1000,1,0,0:0:0:0:
+100,100,1100,1,0,0:0:0:0:
+100,100,1200,1,0,0:0:0:0:
+300,300,1300,1,0,0:0:0:0:
+`))
+
+	objects := standardObjects(m, 1200, 0.5)
+	heights := []int{2, 1, 0, 0}
+	for i, obj := range objects {
+		if obj.stackHeight != heights[i] {
+			t.Errorf("object %d: expected stack height %d, got %d", i, heights[i], obj.stackHeight)
+		}
+	}
+	if p := objects[0].stackedPosition(); p != osu.NewFloatPoint(93.6, 93.6) {
+		t.Errorf("wrong stacked position: %+v", p)
+	}
+}
+
+func TestStandardFlashlight(t *testing.T) {
+	m := loadMap(t, "Tanaka Aimi - KakushintekiMetamorphose! (deadcode) [IT'S SHOWTIME!!!!!].osu")
+	nomod := CalculateStandard(m, 0)
+	if nomod.FlashlightDifficulty != 0 {
+		t.Errorf("expected flashlight to only be rated with Flashlight, got %.2f", nomod.FlashlightDifficulty)
+	}
+
+	fl := CalculateStandard(m, MOD_FLASHLIGHT)
+	if fl.FlashlightDifficulty <= 0 || fl.StarRating <= nomod.StarRating {
+		t.Errorf("expected Flashlight to add difficulty, got %+v", fl)
+	}
+	if fl.AimDifficulty != nomod.AimDifficulty || fl.SpeedDifficulty != nomod.SpeedDifficulty {
+		t.Errorf("expected Flashlight to leave aim and speed alone, got %+v", fl)
+	}
+	expectClose(t, "FL star rating", fl.StarRating, 9.5569, 1e-4)
+	expectClose(t, "flashlight", fl.FlashlightDifficulty, 3.6574, 1e-4)
+
+	// objects fade out with Hidden, so the ones before are harder to see
+	if hdfl := CalculateStandard(m, MOD_HIDDEN|MOD_FLASHLIGHT); hdfl.FlashlightDifficulty <= fl.FlashlightDifficulty {
+		t.Errorf("expected Hidden to make Flashlight harder, got %.2f and %.2f", hdfl.FlashlightDifficulty, fl.FlashlightDifficulty)
+	}
+}
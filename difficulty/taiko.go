@@ -0,0 +1,170 @@
+package difficulty
+
+import (
+	"math"
+
+	osu "github.com/iptq/osu-go"
+)
+
+// TaikoAttributes describes the difficulty of an osu!taiko map.
+type TaikoAttributes struct {
+	StarRating float64
+
+	StaminaDifficulty float64
+	RhythmDifficulty  float64
+	ColourDifficulty  float64
+
+	// GreatHitWindow is the 300 window either side of a hit, in milliseconds
+	// and adjusted for the clock rate
+	GreatHitWindow float64
+
+	MaxCombo int
+}
+
+const (
+	TAIKO_COLOUR_SKILL_MULTIPLIER  = 0.01
+	TAIKO_RHYTHM_SKILL_MULTIPLIER  = 0.014
+	TAIKO_STAMINA_SKILL_MULTIPLIER = 0.02
+)
+
+// taikoRhythm is a ratio between the time since the last hit and the time
+// between the two before that, along with how hard it is to play.
+type taikoRhythm struct {
+	ratio      float64
+	difficulty float64
+}
+
+var taikoCommonRhythms = []*taikoRhythm{
+	{1.0 / 1, 0.0},
+	{2.0 / 1, 0.3},
+	{1.0 / 2, 0.5},
+	{3.0 / 1, 0.3},
+	{1.0 / 3, 0.35},
+	{3.0 / 2, 0.6},
+	{2.0 / 3, 0.4},
+	{5.0 / 4, 0.5},
+	{4.0 / 5, 0.7},
+}
+
+type taikoDifficultyObject struct {
+	obj, last osu.TaikoObject
+
+	// index is the position of the object among all of the taiko objects
+	index int
+
+	startTime float64
+	deltaTime float64
+	rhythm    *taikoRhythm
+
+	// staminaCheese is set for objects in patterns that can be played
+	// alternating hands without much effort
+	staminaCheese bool
+}
+
+func newTaikoDifficultyObject(objects []osu.TaikoObject, i int, clockRate float64) *taikoDifficultyObject {
+	obj, last, lastLast := objects[i], objects[i-1], objects[i-2]
+	d := &taikoDifficultyObject{
+		obj:       obj,
+		last:      last,
+		index:     i,
+		startTime: obj.StartTime / clockRate,
+		deltaTime: (obj.StartTime - last.StartTime) / clockRate,
+	}
+
+	prevLength := (last.StartTime - lastLast.StartTime) / clockRate
+	ratio := d.deltaTime / prevLength
+	for _, rhythm := range taikoCommonRhythms {
+		if d.rhythm == nil || math.Abs(rhythm.ratio-ratio) < math.Abs(d.rhythm.ratio-ratio) {
+			d.rhythm = rhythm
+		}
+	}
+	return d
+}
+
+// CalculateTaiko calculates the difficulty of a map played in osu!taiko,
+// converting it first if it was made for osu!standard.
+func CalculateTaiko(m *osu.Beatmap, mods Mods) TaikoAttributes {
+	settings := AdjustedSettings(m, mods)
+	clockRate := ClockRate(mods)
+	objects := m.TaikoObjects()
+
+	attrs := TaikoAttributes{
+		GreatHitWindow: osu.DifficultyRange(settings.OverallDifficulty, 50, 35, 20) / clockRate,
+	}
+	for _, obj := range objects {
+		if obj.IsHit() {
+			attrs.MaxCombo++
+		}
+	}
+	if len(objects) < 3 {
+		return attrs
+	}
+
+	var diffObjects []*taikoDifficultyObject
+	for i := 2; i < len(objects); i++ {
+		diffObjects = append(diffObjects, newTaikoDifficultyObject(objects, i, clockRate))
+	}
+	findStaminaCheese(diffObjects)
+
+	colour := newTaikoColourSkill(diffObjects)
+	rhythm := newTaikoRhythmSkill(diffObjects)
+	staminaRight := newTaikoStaminaSkill(diffObjects, true)
+	staminaLeft := newTaikoStaminaSkill(diffObjects, false)
+	for i, d := range diffObjects {
+		colour.process(i, d.startTime)
+		rhythm.process(i, d.startTime)
+		staminaRight.process(i, d.startTime)
+		staminaLeft.process(i, d.startTime)
+	}
+
+	colourPeaks := colour.strainPeaks()
+	rhythmPeaks := rhythm.strainPeaks()
+	staminaRightPeaks := staminaRight.strainPeaks()
+	staminaLeftPeaks := staminaLeft.strainPeaks()
+
+	colourRating := weightedSum(colourPeaks, 0.9) * TAIKO_COLOUR_SKILL_MULTIPLIER
+	rhythmRating := weightedSum(rhythmPeaks, 0.9) * TAIKO_RHYTHM_SKILL_MULTIPLIER
+	staminaRating := (weightedSum(staminaRightPeaks, 0.9) + weightedSum(staminaLeftPeaks, 0.9)) * TAIKO_STAMINA_SKILL_MULTIPLIER
+
+	// maps that are all one colour are easier on stamina than they look
+	staminaPenalty := simpleColourPenalty(staminaRating, colourRating)
+	staminaRating *= staminaPenalty
+
+	// combine the skills section by section, as well as overall
+	combinedPeaks := make([]float64, len(colourPeaks))
+	for i := range colourPeaks {
+		colourPeak := colourPeaks[i] * TAIKO_COLOUR_SKILL_MULTIPLIER
+		rhythmPeak := rhythmPeaks[i] * TAIKO_RHYTHM_SKILL_MULTIPLIER
+		staminaPeak := (staminaRightPeaks[i] + staminaLeftPeaks[i]) * TAIKO_STAMINA_SKILL_MULTIPLIER * staminaPenalty
+		combinedPeaks[i] = norm(2, colourPeak, rhythmPeak, staminaPeak)
+	}
+	combinedRating := weightedSum(combinedPeaks, 0.9)
+	separatedRating := norm(1.5, colourRating, rhythmRating, staminaRating)
+
+	starRating := 1.4*separatedRating + 0.5*combinedRating
+	if starRating >= 0 {
+		starRating = 10.43 * math.Log(starRating/8+1)
+	}
+
+	attrs.StarRating = starRating
+	attrs.StaminaDifficulty = staminaRating
+	attrs.RhythmDifficulty = rhythmRating
+	attrs.ColourDifficulty = colourRating
+	return attrs
+}
+
+func simpleColourPenalty(staminaDifficulty, colourDifficulty float64) float64 {
+	if colourDifficulty <= 0 {
+		return 0.79 - 0.25
+	}
+	return 0.79 - math.Atan(staminaDifficulty/colourDifficulty-12)/math.Pi/2
+}
+
+// norm returns the p-norm of the values.
+func norm(p float64, values ...float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += math.Pow(v, p)
+	}
+	return math.Pow(total, 1/p)
+}
@@ -0,0 +1,352 @@
+package difficulty
+
+import (
+	"math"
+
+	osu "github.com/iptq/osu-go"
+)
+
+const (
+	TAIKO_COLOUR_DECAY_BASE  = 0.4
+	TAIKO_STAMINA_DECAY_BASE = 0.4
+
+	TAIKO_RHYTHM_SKILL_MULT     = 10.0
+	TAIKO_RHYTHM_STRAIN_DECAY   = 0.96
+	TAIKO_RHYTHM_HISTORY_LENGTH = 8
+	TAIKO_MONO_HISTORY_LENGTH   = 5
+
+	// how many repetitions it takes for a pattern to count as cheesable
+	TAIKO_ROLL_MIN_REPETITIONS = 12
+	TAIKO_TL_MIN_REPETITIONS   = 16
+)
+
+func taikoTimes(objects []*taikoDifficultyObject) (startTimes []float64, deltaTime func(i int) float64) {
+	startTimes = make([]float64, len(objects))
+	for i, d := range objects {
+		startTimes[i] = d.startTime
+	}
+	return startTimes, func(i int) float64 { return objects[i].deltaTime }
+}
+
+// repetitionPenalty makes patterns count for less if they were repeated
+// recently.
+func repetitionPenalty(notesSince int) float64 {
+	return math.Min(1, 0.032*float64(notesSince))
+}
+
+func newTaikoColourSkill(objects []*taikoDifficultyObject) *strainSkill {
+	var monoHistory []int
+	currentMonoLength := 0
+	var previousHitType *osu.TaikoHitType
+
+	// repetitionPenalties records the length of the streak that just ended,
+	// and penalizes it if the last two streaks have happened before
+	repetitionPenalties := func() float64 {
+		const mostRecentPatternsToCompare = 2
+		penalty := 1.0
+
+		monoHistory = append(monoHistory, currentMonoLength)
+		if len(monoHistory) > TAIKO_MONO_HISTORY_LENGTH {
+			monoHistory = monoHistory[1:]
+		}
+
+		for start := len(monoHistory) - mostRecentPatternsToCompare - 1; start >= 0; start-- {
+			same := true
+			for i := 0; i < mostRecentPatternsToCompare; i++ {
+				if monoHistory[start+i] != monoHistory[len(monoHistory)-mostRecentPatternsToCompare+i] {
+					same = false
+					break
+				}
+			}
+			if !same {
+				continue
+			}
+
+			notesSince := 0
+			for i := start; i < len(monoHistory); i++ {
+				notesSince += monoHistory[i]
+			}
+			penalty *= repetitionPenalty(notesSince)
+			break
+		}
+		return penalty
+	}
+
+	strainValueOf := func(i int) float64 {
+		curr := objects[i]
+
+		// changing to or from a drumroll or swell isn't a colour change, and
+		// neither is anything after a long enough gap
+		if !(curr.last.IsHit() && curr.obj.IsHit() && curr.deltaTime < 1000) {
+			monoHistory = nil
+			currentMonoLength = 0
+			previousHitType = nil
+			if curr.obj.IsHit() {
+				currentMonoLength = 1
+				hitType := curr.obj.Type
+				previousHitType = &hitType
+			}
+			return 0
+		}
+
+		objectStrain := 0.0
+		if previousHitType != nil && curr.obj.Type != *previousHitType {
+			objectStrain = 1
+
+			// there have to be at least two streaks to compare, and a streak
+			// with the same parity as the last one isn't any harder
+			if len(monoHistory) < 2 || (monoHistory[len(monoHistory)-1]+currentMonoLength)%2 == 0 {
+				objectStrain = 0
+			}
+
+			objectStrain *= repetitionPenalties()
+			currentMonoLength = 1
+		} else {
+			currentMonoLength++
+		}
+
+		hitType := curr.obj.Type
+		previousHitType = &hitType
+		return objectStrain
+	}
+
+	startTimes, deltaTime := taikoTimes(objects)
+	return strainDecaySkill(startTimes, TAIKO_COLOUR_DECAY_BASE, 1, deltaTime, strainValueOf)
+}
+
+func newTaikoRhythmSkill(objects []*taikoDifficultyObject) *strainSkill {
+	var rhythmHistory []*taikoDifficultyObject
+	currentStrain := 0.0
+	notesSinceRhythmChange := 0
+
+	reset := func() {
+		currentStrain = 0
+		notesSinceRhythmChange = 0
+	}
+
+	repetitionPenalties := func(curr *taikoDifficultyObject) float64 {
+		penalty := 1.0
+
+		rhythmHistory = append(rhythmHistory, curr)
+		if len(rhythmHistory) > TAIKO_RHYTHM_HISTORY_LENGTH {
+			rhythmHistory = rhythmHistory[1:]
+		}
+
+		for patternLength := 2; patternLength <= TAIKO_RHYTHM_HISTORY_LENGTH/2; patternLength++ {
+			for start := len(rhythmHistory) - patternLength - 1; start >= 0; start-- {
+				same := true
+				for i := 0; i < patternLength; i++ {
+					if rhythmHistory[start+i].rhythm != rhythmHistory[len(rhythmHistory)-patternLength+i].rhythm {
+						same = false
+						break
+					}
+				}
+				if !same {
+					continue
+				}
+
+				penalty *= repetitionPenalty(curr.index - rhythmHistory[start].index)
+				break
+			}
+		}
+		return penalty
+	}
+
+	patternLengthPenalty := func(patternLength int) float64 {
+		shortPatternPenalty := math.Min(0.15*float64(patternLength), 1)
+		longPatternPenalty := clamp(2.5-0.15*float64(patternLength), 0, 1)
+		return math.Min(shortPatternPenalty, longPatternPenalty)
+	}
+
+	// rhythm changes are only hard at high enough speeds
+	speedPenalty := func(deltaTime float64) float64 {
+		if deltaTime < 80 {
+			return 1
+		}
+		if deltaTime < 210 {
+			return math.Max(0, 1.4-0.005*deltaTime)
+		}
+		reset()
+		return 0
+	}
+
+	strainValueOf := func(i int) float64 {
+		curr := objects[i]
+		if !curr.obj.IsHit() {
+			reset()
+			return 0
+		}
+
+		currentStrain *= TAIKO_RHYTHM_STRAIN_DECAY
+		notesSinceRhythmChange++
+
+		if curr.rhythm.difficulty == 0 {
+			return 0
+		}
+
+		objectStrain := curr.rhythm.difficulty
+		objectStrain *= repetitionPenalties(curr)
+		objectStrain *= patternLengthPenalty(notesSinceRhythmChange)
+		objectStrain *= speedPenalty(curr.deltaTime)
+
+		notesSinceRhythmChange = 0
+		currentStrain += objectStrain
+		return currentStrain
+	}
+
+	// the strain is kept track of here rather than decaying over time
+	startTimes, deltaTime := taikoTimes(objects)
+	return strainDecaySkill(startTimes, 0, TAIKO_RHYTHM_SKILL_MULT, deltaTime, strainValueOf)
+}
+
+// newTaikoStaminaSkill measures the strain on one hand, assuming the player
+// alternates hands on every hit.
+func newTaikoStaminaSkill(objects []*taikoDifficultyObject, rightHand bool) *strainSkill {
+	hand := 0
+	if rightHand {
+		hand = 1
+	}
+
+	var notePairDurationHistory []float64
+	offhandObjectDuration := math.MaxFloat64
+
+	speedBonus := func(notePairDuration float64) float64 {
+		if notePairDuration >= 200 {
+			return 0
+		}
+		bonus := 200 - notePairDuration
+		return bonus * bonus / 100000
+	}
+
+	cheesePenalty := func(notePairDuration float64) float64 {
+		if notePairDuration > 125 {
+			return 1
+		}
+		if notePairDuration < 100 {
+			return 0.6
+		}
+		return 0.6 + (notePairDuration-100)*0.016
+	}
+
+	strainValueOf := func(i int) float64 {
+		curr := objects[i]
+		if !curr.obj.IsHit() {
+			return 0
+		}
+
+		if curr.index%2 != hand {
+			offhandObjectDuration = curr.deltaTime
+			return 0
+		}
+
+		objectStrain := 1.0
+		if curr.index == 1 {
+			return 1
+		}
+
+		notePairDurationHistory = append(notePairDurationHistory, curr.deltaTime+offhandObjectDuration)
+		if len(notePairDurationHistory) > 2 {
+			notePairDurationHistory = notePairDurationHistory[1:]
+		}
+
+		shortestRecentNote := math.MaxFloat64
+		for _, duration := range notePairDurationHistory {
+			shortestRecentNote = math.Min(shortestRecentNote, duration)
+		}
+		objectStrain += speedBonus(shortestRecentNote)
+
+		if curr.staminaCheese {
+			objectStrain *= cheesePenalty(curr.deltaTime + offhandObjectDuration)
+		}
+		return objectStrain
+	}
+
+	startTimes, deltaTime := taikoTimes(objects)
+	return strainDecaySkill(startTimes, TAIKO_STAMINA_DECAY_BASE, 1, deltaTime, strainValueOf)
+}
+
+// findStaminaCheese marks objects in patterns that can be played without
+// much stamina: rolls like "ddkddk", and alternating single-hand patterns
+// like "d_d_d_d_".
+func findStaminaCheese(objects []*taikoDifficultyObject) {
+	markAsCheese := func(start, end int) {
+		for i := start; i <= end; i++ {
+			objects[i].staminaCheese = true
+		}
+	}
+
+	hitType := func(d *taikoDifficultyObject) osu.TaikoHitType {
+		if !d.obj.IsHit() {
+			return -1
+		}
+		return d.obj.Type
+	}
+
+	findRolls := func(patternLength int) {
+		historyLength := 2 * patternLength
+		indexBeforeLastRepeat := -1
+		lastMarkEnd := 0
+
+		for i := range objects {
+			if i+1 < historyLength {
+				continue
+			}
+
+			history := objects[i+1-historyLength : i+1]
+			repeated := true
+			for j := 0; j < patternLength; j++ {
+				if hitType(history[j]) != hitType(history[j+patternLength]) {
+					repeated = false
+					break
+				}
+			}
+			if !repeated {
+				indexBeforeLastRepeat = i - historyLength + 1
+				continue
+			}
+
+			repeatedLength := i - indexBeforeLastRepeat
+			if repeatedLength < TAIKO_ROLL_MIN_REPETITIONS {
+				continue
+			}
+
+			start := i - repeatedLength + 1
+			if lastMarkEnd > start {
+				start = lastMarkEnd
+			}
+			markAsCheese(start, i)
+			lastMarkEnd = i
+		}
+	}
+
+	findTlTap := func(parity int, ty osu.TaikoHitType) {
+		tlLength := -2
+		lastMarkEnd := 0
+
+		for i := parity; i < len(objects); i += 2 {
+			if hitType(objects[i]) == ty {
+				tlLength += 2
+			} else {
+				tlLength = -2
+			}
+
+			if tlLength < TAIKO_TL_MIN_REPETITIONS {
+				continue
+			}
+
+			start := i - tlLength + 1
+			if lastMarkEnd > start {
+				start = lastMarkEnd
+			}
+			markAsCheese(start, i)
+			lastMarkEnd = i
+		}
+	}
+
+	findRolls(3)
+	findRolls(4)
+	findTlTap(0, osu.TAIKO_KAT)
+	findTlTap(1, osu.TAIKO_KAT)
+	findTlTap(0, osu.TAIKO_DON)
+	findTlTap(1, osu.TAIKO_DON)
+}
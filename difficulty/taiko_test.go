@@ -0,0 +1,51 @@
+package difficulty
+
+import "testing"
+
+func TestTaikoDifficulty(t *testing.T) {
+	names := []string{
+		"Minami Kuribayashi - ZERO!! (Short Size) (qoot8123) [Kantan].osu",
+		"Minami Kuribayashi - ZERO!! (Short Size) (qoot8123) [Futsuu].osu",
+		"Minami Kuribayashi - ZERO!! (Short Size) (qoot8123) [Muzukashii].osu",
+		"Minami Kuribayashi - ZERO!! (Short Size) (qoot8123) [Oni].osu",
+		"Minami Kuribayashi - ZERO!! (Short Size) (qoot8123) [Inner Oni].osu",
+	}
+
+	last := 0.0
+	for _, name := range names {
+		attrs := CalculateTaiko(loadMap(t, name), 0)
+		if attrs.StarRating <= last {
+			t.Errorf("%s: expected more than %.2f stars, got %.2f", name, last, attrs.StarRating)
+		}
+		last = attrs.StarRating
+	}
+
+	m := loadMap(t, "Minami Kuribayashi - ZERO!! (Short Size) (qoot8123) [Oni].osu")
+	nomod := CalculateTaiko(m, 0)
+	if nomod.StarRating < 3 || nomod.StarRating > 5 {
+		t.Errorf("expected around 4 stars, got %.2f", nomod.StarRating)
+	}
+	if nomod.ColourDifficulty <= 0 || nomod.RhythmDifficulty <= 0 || nomod.StaminaDifficulty <= 0 {
+		t.Errorf("expected every skill to contribute, got %+v", nomod)
+	}
+
+	hits := 0
+	for _, obj := range m.TaikoObjects() {
+		if obj.IsHit() {
+			hits++
+		}
+	}
+	if nomod.MaxCombo != hits || nomod.MaxCombo != 418 {
+		t.Errorf("expected max combo %d, got %d", hits, nomod.MaxCombo)
+	}
+	expectClose(t, "star rating", nomod.StarRating, 3.7414, 1e-4)
+	expectClose(t, "stamina", nomod.StaminaDifficulty, 1.6910, 1e-4)
+	expectClose(t, "rhythm", nomod.RhythmDifficulty, 0.27311, 1e-4)
+	expectClose(t, "colour", nomod.ColourDifficulty, 0.32405, 1e-4)
+	expectClose(t, "great hit window", nomod.GreatHitWindow, 33.5, 1e-4)
+
+	dt := CalculateTaiko(m, MOD_DOUBLETIME)
+	if dt.StarRating <= nomod.StarRating || dt.GreatHitWindow >= nomod.GreatHitWindow {
+		t.Errorf("expected DT to be harder, got %+v", dt)
+	}
+}
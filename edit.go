@@ -0,0 +1,185 @@
+package osu
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/oklog/ulid"
+)
+
+// The editing operations below keep HitObjects sorted by start time, with
+// objects at the same time kept in the order they were added. They also
+// maintain an index of objects by ULID, which they keep up to date as they go.
+// Code that changes HitObjects directly should call ReindexHitObjects after.
+
+// hitObjectIndex returns the index of objects by ULID, building it first if
+// it's missing.
+func (m *Beatmap) hitObjectIndex() map[ulid.ULID]*HitObject {
+	if m.hitObjectsByULID == nil {
+		m.buildHitObjectIndex()
+	}
+	return m.hitObjectsByULID
+}
+
+func (m *Beatmap) buildHitObjectIndex() {
+	m.hitObjectsByULID = make(map[ulid.ULID]*HitObject, len(m.HitObjects))
+	for _, obj := range m.HitObjects {
+		m.hitObjectsByULID[(*obj).GetULID()] = obj
+	}
+}
+
+// ReindexHitObjects sorts HitObjects by start time and rebuilds the index of
+// objects by ULID. This only needs to be called after changing HitObjects
+// directly.
+func (m *Beatmap) ReindexHitObjects() {
+	sort.SliceStable(m.HitObjects, func(i, j int) bool {
+		return (*m.HitObjects[i]).GetStartTime().Milliseconds() < (*m.HitObjects[j]).GetStartTime().Milliseconds()
+	})
+	m.buildHitObjectIndex()
+}
+
+// HitObjectByULID looks up a hit object by its ULID.
+func (m *Beatmap) HitObjectByULID(id ulid.ULID) (HitObject, bool) {
+	obj, ok := m.hitObjectIndex()[id]
+	if !ok {
+		return nil, false
+	}
+	return *obj, true
+}
+
+// HitObjectsBetween returns the hit objects that start at or after start, and
+// before end, in order. The returned slice is a copy, so it can be kept while
+// the map is edited.
+func (m *Beatmap) HitObjectsBetween(start, end int) []*HitObject {
+	lo := m.searchHitObjects(start)
+	hi := m.searchHitObjects(end)
+	if hi < lo {
+		hi = lo
+	}
+	return append([]*HitObject(nil), m.HitObjects[lo:hi]...)
+}
+
+// searchHitObjects returns the index of the first object starting at or after
+// the given time.
+func (m *Beatmap) searchHitObjects(time int) int {
+	return sort.Search(len(m.HitObjects), func(i int) bool {
+		return (*m.HitObjects[i]).GetStartTime().Milliseconds() >= time
+	})
+}
+
+// findHitObject returns the position of the object with the given ULID in
+// HitObjects, or -1 if there isn't one.
+func (m *Beatmap) findHitObject(id ulid.ULID) int {
+	obj, ok := m.hitObjectIndex()[id]
+	if !ok {
+		return -1
+	}
+
+	// the object is somewhere among the ones starting at the same time
+	time := (*obj).GetStartTime().Milliseconds()
+	for i := m.searchHitObjects(time); i < len(m.HitObjects); i++ {
+		if m.HitObjects[i] == obj {
+			return i
+		}
+		if (*m.HitObjects[i]).GetStartTime().Milliseconds() > time {
+			break
+		}
+	}
+
+	// the objects weren't in order, so look through all of them
+	for i := range m.HitObjects {
+		if m.HitObjects[i] == obj {
+			return i
+		}
+	}
+	return -1
+}
+
+// InsertHitObject adds an object to the map, after any other objects at the
+// same time.
+func (m *Beatmap) InsertHitObject(obj HitObject) error {
+	index := m.hitObjectIndex()
+	if _, exists := index[obj.GetULID()]; exists {
+		return fmt.Errorf("hit object with ULID %s already exists", obj.GetULID())
+	}
+
+	time := obj.GetStartTime().Milliseconds()
+	i := m.searchHitObjects(time + 1)
+
+	m.HitObjects = append(m.HitObjects, nil)
+	copy(m.HitObjects[i+1:], m.HitObjects[i:])
+	m.HitObjects[i] = &obj
+	index[obj.GetULID()] = &obj
+	return nil
+}
+
+// RemoveHitObject removes the object with the given ULID, and returns it.
+func (m *Beatmap) RemoveHitObject(id ulid.ULID) (HitObject, error) {
+	i := m.findHitObject(id)
+	if i < 0 {
+		return nil, fmt.Errorf("no hit object with ULID %s", id)
+	}
+
+	obj := *m.HitObjects[i]
+	m.HitObjects = append(m.HitObjects[:i], m.HitObjects[i+1:]...)
+	delete(m.hitObjectIndex(), id)
+	return obj, nil
+}
+
+// ReplaceHitObject swaps the object with the given ULID for another one,
+// which will usually be a modified copy with the same ULID.
+func (m *Beatmap) ReplaceHitObject(id ulid.ULID, obj HitObject) error {
+	if obj.GetULID() != id {
+		if _, exists := m.hitObjectIndex()[obj.GetULID()]; exists {
+			return fmt.Errorf("hit object with ULID %s already exists", obj.GetULID())
+		}
+	}
+
+	if _, err := m.RemoveHitObject(id); err != nil {
+		return err
+	}
+	return m.InsertHitObject(obj)
+}
+
+// MoveHitObject moves the object with the given ULID to a new time and
+// position. Anything else about the object that depends on these, like the
+// end time of a spinner or the path of a slider, moves along with it.
+func (m *Beatmap) MoveHitObject(id ulid.ULID, startTime Timestamp, position IntPoint) error {
+	obj, ok := m.HitObjectByULID(id)
+	if !ok {
+		return fmt.Errorf("no hit object with ULID %s", id)
+	}
+	return m.ReplaceHitObject(id, movedHitObject(obj, startTime, position))
+}
+
+func movedHitObject(obj HitObject, startTime Timestamp, position IntPoint) HitObject {
+	dt := startTime.Milliseconds() - obj.GetStartTime().Milliseconds()
+	switch o := obj.(type) {
+	case ObjCircle:
+		o.startTime, o.x, o.y = startTime, position.x, position.y
+		return o
+	case ObjSlider:
+		dx, dy := position.x-o.x, position.y-o.y
+		ctlPoints := make([]IntPoint, len(o.ctlPoints))
+		for i, p := range o.ctlPoints {
+			ctlPoints[i] = IntPoint{p.x + dx, p.y + dy}
+		}
+		spline := make([]FloatPoint, len(o.spline))
+		for i, p := range o.spline {
+			spline[i] = FloatPoint{p.x + float64(dx), p.y + float64(dy)}
+		}
+		o.startTime, o.x, o.y = startTime, position.x, position.y
+		o.ctlPoints, o.spline = ctlPoints, spline
+		return o
+	case ObjSpinner:
+		// spinners are always in the middle, so they can only move in time
+		o.startTime = startTime
+		o.endTime = TimestampAbsolute(o.endTime.Milliseconds() + dt)
+		return o
+	case ObjHoldNote:
+		o.startTime, o.x, o.y = startTime, position.x, position.y
+		o.endTime = TimestampAbsolute(o.endTime.Milliseconds() + dt)
+		return o
+	}
+	return obj
+}
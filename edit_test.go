@@ -0,0 +1,141 @@
+package osu
+
+import (
+	"strings"
+	"testing"
+)
+
+func startTimes(m *Beatmap) []int {
+	times := make([]int, len(m.HitObjects))
+	for i, obj := range m.HitObjects {
+		times[i] = (*obj).GetStartTime().Milliseconds()
+	}
+	return times
+}
+
+func TestEditHitObjects(t *testing.T) {
+	m, err := ParseBeatmap(strings.NewReader(maniaMap))
+	if err != nil {
+		t.Fatalf("failed to parse mania map: %v", err)
+	}
+
+	circle := NewCircle(NewIntPoint(320, 192), TimestampAbsolute(1500), false, 0, Extras{})
+	if err := m.InsertHitObject(circle); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+	if err := m.InsertHitObject(circle); err == nil {
+		t.Error("expected inserting the same object twice to fail")
+	}
+	if times := startTimes(m); len(times) != 4 || times[2] != 1500 {
+		t.Errorf("expected object to be inserted in order, got %v", times)
+	}
+
+	obj, ok := m.HitObjectByULID(circle.GetULID())
+	if !ok || obj.GetStartTime().Milliseconds() != 1500 {
+		t.Errorf("failed to look up inserted object: %+v", obj)
+	}
+
+	if between := m.HitObjectsBetween(1000, 2000); len(between) != 3 {
+		t.Errorf("expected 3 objects between 1000 and 2000, got %d", len(between))
+	}
+
+	// moving a hold note keeps its length
+	hold := *m.HitObjects[3]
+	if err := m.MoveHitObject(hold.GetULID(), TimestampAbsolute(500), NewIntPoint(64, 192)); err != nil {
+		t.Fatalf("failed to move: %v", err)
+	}
+	if times := startTimes(m); times[0] != 500 {
+		t.Errorf("expected moved object to come first, got %v", times)
+	}
+	moved, _ := m.HitObjectByULID(hold.GetULID())
+	if end := moved.(ObjHoldNote).GetEndTime().Milliseconds(); end != 1250 {
+		t.Errorf("expected hold note to end at 1250, got %d", end)
+	}
+	if moved.(ObjHoldNote).Column(m.KeyCount()) != 0 {
+		t.Errorf("expected hold note to move to column 0")
+	}
+
+	replacement := NewCircle(NewIntPoint(0, 0), TimestampAbsolute(3000), false, 0, Extras{})
+	if err := m.ReplaceHitObject(circle.GetULID(), replacement); err != nil {
+		t.Fatalf("failed to replace: %v", err)
+	}
+	if _, ok := m.HitObjectByULID(circle.GetULID()); ok {
+		t.Error("expected replaced object to be gone")
+	}
+	if times := startTimes(m); times[len(times)-1] != 3000 {
+		t.Errorf("expected replacement at the end, got %v", times)
+	}
+
+	if _, err := m.RemoveHitObject(replacement.GetULID()); err != nil {
+		t.Fatalf("failed to remove: %v", err)
+	}
+	if _, err := m.RemoveHitObject(replacement.GetULID()); err == nil {
+		t.Error("expected removing twice to fail")
+	}
+	if len(m.HitObjects) != 3 {
+		t.Errorf("expected 3 objects left, got %d", len(m.HitObjects))
+	}
+}
+
+func TestMoveSlider(t *testing.T) {
+	slider, _ := NewSlider(NewIntPoint(0, 0), TimestampAbsolute(0), false, 0, Extras{}, SPLINE_LINEAR, []IntPoint{NewIntPoint(100, 0)}, 1, 100)
+	m := &Beatmap{}
+	m.InsertHitObject(slider)
+	m.MoveHitObject(slider.GetULID(), TimestampAbsolute(100), NewIntPoint(50, 50))
+
+	obj, _ := m.HitObjectByULID(slider.GetULID())
+	moved := obj.(ObjSlider)
+	if end := moved.EndPosition(); end != NewFloatPoint(150, 50) {
+		t.Errorf("expected path to move with the slider, ends at %+v", end)
+	}
+	if line, _ := moved.Serialize(); line != "50,50,100,2,0,L|150:50,1,100" {
+		t.Errorf("wrong serialization: '%s'", line)
+	}
+}
+
+func TestHitObjectIndex(t *testing.T) {
+	m, err := ParseBeatmap(strings.NewReader(maniaMap))
+	if err != nil {
+		t.Fatalf("failed to parse mania map: %v", err)
+	}
+
+	between := m.HitObjectsBetween(0, 5000)
+	if len(between) != 3 {
+		t.Fatalf("expected 3 objects, got %d", len(between))
+	}
+	first := between[0]
+	if err := m.InsertHitObject(NewCircle(NewIntPoint(0, 0), TimestampAbsolute(0), false, 0, Extras{})); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+	if between[0] != first {
+		t.Error("expected HitObjectsBetween to return a copy")
+	}
+
+	// looking things up shouldn't reorder objects that were put out of order
+	m.HitObjects[0], m.HitObjects[3] = m.HitObjects[3], m.HitObjects[0]
+	last := *m.HitObjects[3]
+	if _, ok := m.HitObjectByULID(last.GetULID()); !ok {
+		t.Error("failed to look up object")
+	}
+	if times := startTimes(m); times[0] != 2000 || times[3] != 0 {
+		t.Errorf("expected lookup to leave the order alone, got %v", times)
+	}
+	if _, err := m.RemoveHitObject(last.GetULID()); err != nil {
+		t.Errorf("failed to remove object that's out of order: %v", err)
+	}
+
+	// objects added directly are picked up after reindexing
+	circle := NewCircle(NewIntPoint(0, 0), TimestampAbsolute(500), false, 0, Extras{})
+	var obj HitObject = circle
+	m.HitObjects = append(m.HitObjects, &obj)
+	if _, ok := m.HitObjectByULID(circle.GetULID()); ok {
+		t.Error("expected object added directly to be missing before reindexing")
+	}
+	m.ReindexHitObjects()
+	if _, ok := m.HitObjectByULID(circle.GetULID()); !ok {
+		t.Error("expected object to be found after reindexing")
+	}
+	if times := startTimes(m); times[0] != 500 || times[len(times)-1] != 2000 {
+		t.Errorf("expected reindexing to sort objects, got %v", times)
+	}
+}
@@ -0,0 +1,52 @@
+package osu
+
+import (
+	"strconv"
+	"strings"
+)
+
+// EditorSettings are the values from the [Editor] section. They don't affect
+// gameplay, but are saved so that the mapper's workspace is restored when the
+// map is opened again.
+type EditorSettings struct {
+	Bookmarks       []int
+	DistanceSpacing float64
+	BeatDivisor     int
+	GridSize        int
+	TimelineZoom    float64
+}
+
+// DefaultEditorSettings returns the settings the editor uses for a map that
+// doesn't specify them.
+func DefaultEditorSettings() EditorSettings {
+	return EditorSettings{
+		DistanceSpacing: 1,
+		BeatDivisor:     4,
+		GridSize:        4,
+		TimelineZoom:    1,
+	}
+}
+
+func ParseBookmarks(line string) (bookmarks []int, err error) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+
+	for _, s := range strings.Split(line, ",") {
+		var bookmark int
+		bookmark, err = strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+	return
+}
+
+func (settings EditorSettings) BookmarksString() string {
+	parts := make([]string, len(settings.Bookmarks))
+	for i, bookmark := range settings.Bookmarks {
+		parts[i] = strconv.Itoa(bookmark)
+	}
+	return strings.Join(parts, ",")
+}
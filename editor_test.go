@@ -0,0 +1,51 @@
+package osu
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const editorSection = `osu file format v14
+
+[General]
+AudioFilename: audio.mp3
+
+[Editor]
+Bookmarks: 16090,31450,46810
+DistanceSpacing: 0.8
+BeatDivisor: 6
+GridSize: 8
+TimelineZoom: 2.5
+`
+
+func TestEditor(t *testing.T) {
+	m, err := ParseBeatmap(strings.NewReader(editorSection))
+	if err != nil {
+		t.Fatalf("failed to parse editor settings: %v", err)
+	}
+
+	expected := EditorSettings{
+		Bookmarks:       []int{16090, 31450, 46810},
+		DistanceSpacing: 0.8,
+		BeatDivisor:     6,
+		GridSize:        8,
+		TimelineZoom:    2.5,
+	}
+	if !reflect.DeepEqual(m.Editor, expected) {
+		t.Errorf("expected %+v, got %+v", expected, m.Editor)
+	}
+
+	var buf bytes.Buffer
+	if err = m.Serialize(&buf); err != nil {
+		t.Fatalf("failed to serialize: %v", err)
+	}
+	m2, err := ParseBeatmap(&buf)
+	if err != nil {
+		t.Fatalf("failed to reparse: %v", err)
+	}
+	if !reflect.DeepEqual(m2.Editor, expected) {
+		t.Errorf("bookmarks didn't survive serialization: %+v", m2.Editor)
+	}
+}
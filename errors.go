@@ -0,0 +1,47 @@
+package osu
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNoMatch means a line wasn't in the format expected by its section.
+	ErrNoMatch = errors.New("failed to match")
+
+	// ErrUnknownSection means a section isn't recognized. It's only ever a
+	// warning, since the section is kept in UnknownSections.
+	ErrUnknownSection = errors.New("unknown section")
+
+	// ErrSkippedCommand means a storyboard command was skipped because the
+	// object it belongs to couldn't be parsed.
+	ErrSkippedCommand = errors.New("storyboard command belongs to an invalid event")
+)
+
+// ParseError describes a problem with a single line of a beatmap. Use
+// errors.Is or errors.As on Err to find out more about what went wrong.
+type ParseError struct {
+	// Line and Column are where the problem is, starting from 1. Line is 0
+	// for problems that aren't on any particular line.
+	Line   int
+	Column int
+
+	// Section is the name of the section the line is in, like "HitObjects"
+	Section string
+
+	// Raw is the line exactly as it appears in the file
+	Raw string
+
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("[%s]\t%s", e.Section, e.Err)
+	}
+	return fmt.Sprintf("line %d [%s]\t%s (line: '%s')", e.Line, e.Section, e.Err, e.Raw)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,184 @@
+package osu
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const brokenMap = `osu file format v14
+
+[General]
+Mode: 0
+this isn't a key
+
+[Nonsense]
+a
+b
+
+[HitObjects]
+256,192,1000,1,0,0:0:0:0:
+256,192,oops,1,0,0:0:0:0:
+256,192,2000,1,0,0:0:0:0:
+`
+
+func TestParseErrors(t *testing.T) {
+	_, err := ParseBeatmap(strings.NewReader(brokenMap))
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a ParseError, got %v", err)
+	}
+	if perr.Line != 5 || perr.Column != 1 || perr.Section != "General" || perr.Raw != "this isn't a key" {
+		t.Errorf("wrong error: %+v", perr)
+	}
+	if !errors.Is(err, ErrNoMatch) {
+		t.Errorf("expected ErrNoMatch, got %v", perr.Err)
+	}
+
+	m, warnings, err := ParseBeatmapLenient(strings.NewReader(brokenMap))
+	if err != nil {
+		t.Fatalf("lenient parsing failed: %v", err)
+	}
+	if len(m.HitObjects) != 2 {
+		t.Errorf("expected the 2 valid hit objects, got %d", len(m.HitObjects))
+	}
+
+	lines := []int{5, 7, 13}
+	if len(warnings) != len(lines) {
+		t.Fatalf("expected %d warnings, got %v", len(lines), warnings)
+	}
+	for i, w := range warnings {
+		if w.Line != lines[i] {
+			t.Errorf("expected warning %d on line %d, got %v", i, lines[i], w)
+		}
+	}
+	if !errors.Is(warnings[1], ErrUnknownSection) || warnings[1].Raw != "[Nonsense]" || warnings[2].Section != "HitObjects" {
+		t.Errorf("wrong warnings: %v", warnings)
+	}
+}
+
+const unknownSection = `osu file format v14
+
+[general]
+Mode: 0
+SomethingNew: 1
+
+[Nonsense]
+a
+  b
+
+[HitObjects]
+256,192,1000,1,0,0:0:0:0:
+`
+
+func TestUnknownSections(t *testing.T) {
+	// unknown sections aren't a problem, even when parsing strictly
+	m, err := ParseBeatmap(strings.NewReader(unknownSection))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if len(m.UnknownSections) != 1 || m.UnknownSections[0].Name != "Nonsense" || len(m.UnknownSections[0].Lines) != 2 {
+		t.Errorf("wrong unknown sections: %+v", m.UnknownSections)
+	}
+	if len(m.ExtraFields["general"]) != 1 {
+		t.Errorf("expected extra fields under the section's name as written, got %+v", m.ExtraFields)
+	}
+
+	var buf bytes.Buffer
+	if err = m.Serialize(&buf); err != nil {
+		t.Fatalf("failed to serialize: %v", err)
+	}
+	if !strings.Contains(buf.String(), "SomethingNew: 1\n") || !strings.Contains(buf.String(), "[Nonsense]\na\n  b\n") {
+		t.Errorf("unknown lines weren't kept:\n%s", buf.String())
+	}
+}
+
+const brokenStoryboard = `osu file format v14
+
+[Events]
+Sprite,Foreground,Centre,"a.png",320,240
+ F,0,1000,2000,0,1
+Sprite,Foreground,Nowhere,"b.png",320,240
+ F,0,1000,2000,0,1
+ L,3000,4
+  S,0,0,500,1,0.5
+Sprite,Foreground,Centre,"c.png",320,240
+ M,0,1000,1000,320,240
+`
+
+func TestParseErrorsStoryboard(t *testing.T) {
+	m, warnings, err := ParseBeatmapLenient(strings.NewReader(brokenStoryboard))
+	if err != nil {
+		t.Fatalf("lenient parsing failed: %v", err)
+	}
+
+	// the commands under the broken sprite are skipped, rather than being
+	// given to the sprite before it
+	lines := []int{6, 7, 8, 9}
+	if len(warnings) != len(lines) {
+		t.Fatalf("expected %d warnings, got %v", len(lines), warnings)
+	}
+	for i, w := range warnings {
+		if w.Line != lines[i] {
+			t.Errorf("expected warning %d on line %d, got %v", i, lines[i], w)
+		}
+		if i > 0 && !errors.Is(w, ErrSkippedCommand) {
+			t.Errorf("expected ErrSkippedCommand, got %v", w)
+		}
+	}
+
+	if len(m.Events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(m.Events))
+	}
+	for i, ev := range m.Events {
+		if commands := ev.(*Sprite).Commands; len(commands) != 1 {
+			t.Errorf("expected event %d to have 1 command, got %d", i, len(commands))
+		}
+	}
+}
+
+const badValues = `osu file format v14
+
+[General]
+AudioLeadIn: abc
+Mode: 1
+
+[Editor]
+Bookmarks: 100,oops
+
+[Difficulty]
+CircleSize: big
+ApproachRate: 9
+`
+
+func TestParseErrorsValues(t *testing.T) {
+	_, err := ParseBeatmap(strings.NewReader(badValues))
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a ParseError, got %v", err)
+	}
+	if perr.Line != 4 || perr.Section != "General" || perr.Raw != "AudioLeadIn: abc" {
+		t.Errorf("wrong error: %+v", perr)
+	}
+
+	m, warnings, err := ParseBeatmapLenient(strings.NewReader(badValues))
+	if err != nil {
+		t.Fatalf("lenient parsing failed: %v", err)
+	}
+	lines := []int{4, 8, 11}
+	if len(warnings) != len(lines) {
+		t.Fatalf("expected %d warnings, got %v", len(lines), warnings)
+	}
+	for i, w := range warnings {
+		if w.Line != lines[i] {
+			t.Errorf("expected warning %d on line %d, got %v", i, lines[i], w)
+		}
+	}
+
+	// the values around the bad ones are still read, and the bad ones are
+	// left at their defaults
+	if m.Mode != MODE_TAIKO || m.ApproachRate != 9 || m.AudioLeadIn != 0 || m.Editor.Bookmarks != nil {
+		t.Errorf("wrong values: %+v", m)
+	}
+}
@@ -0,0 +1,532 @@
+package osu
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type StoryboardLayer = int
+
+const (
+	LAYER_BACKGROUND = 0
+	LAYER_FAIL       = 1
+	LAYER_PASS       = 2
+	LAYER_FOREGROUND = 3
+	LAYER_OVERLAY    = 4
+)
+
+var STORYBOARD_LAYERS = map[int]string{0: "Background", 1: "Fail", 2: "Pass", 3: "Foreground", 4: "Overlay"}
+var STORYBOARD_LAYERS_INV = map[string]int{"background": 0, "fail": 1, "pass": 2, "foreground": 3, "overlay": 4}
+
+type StoryboardOrigin = int
+
+const (
+	ORIGIN_TOP_LEFT      = 0
+	ORIGIN_CENTRE        = 1
+	ORIGIN_CENTRE_LEFT   = 2
+	ORIGIN_TOP_RIGHT     = 3
+	ORIGIN_BOTTOM_CENTRE = 4
+	ORIGIN_TOP_CENTRE    = 5
+	ORIGIN_CUSTOM        = 6
+	ORIGIN_CENTRE_RIGHT  = 7
+	ORIGIN_BOTTOM_LEFT   = 8
+	ORIGIN_BOTTOM_RIGHT  = 9
+)
+
+var STORYBOARD_ORIGINS = map[int]string{
+	0: "TopLeft", 1: "Centre", 2: "CentreLeft", 3: "TopRight", 4: "BottomCentre",
+	5: "TopCentre", 6: "Custom", 7: "CentreRight", 8: "BottomLeft", 9: "BottomRight",
+}
+var STORYBOARD_ORIGINS_INV = map[string]int{
+	"topleft": 0, "centre": 1, "centreleft": 2, "topright": 3, "bottomcentre": 4,
+	"topcentre": 5, "custom": 6, "centreright": 7, "bottomleft": 8, "bottomright": 9,
+}
+
+type AnimationLoopType = int
+
+const (
+	LOOP_FOREVER = 0
+	LOOP_ONCE    = 1
+)
+
+var ANIMATION_LOOP_TYPES = map[int]string{0: "LoopForever", 1: "LoopOnce"}
+var ANIMATION_LOOP_TYPES_INV = map[string]int{"loopforever": 0, "looponce": 1}
+
+// Event is a single top-level entry in the [Events] section. Storyboard
+// objects serialize to more than one line, since their commands are written
+// out right after them.
+type Event interface {
+	Serialize() (string, error)
+}
+
+// Background is the image shown behind the playfield.
+type Background struct {
+	Filename         string
+	XOffset, YOffset int
+}
+
+func (ev *Background) Serialize() (string, error) {
+	return fmt.Sprintf("0,0,\"%s\",%d,%d", ev.Filename, ev.XOffset, ev.YOffset), nil
+}
+
+// Video is a background video, starting at StartTime.
+type Video struct {
+	StartTime        Timestamp
+	Filename         string
+	XOffset, YOffset int
+}
+
+func (ev *Video) Serialize() (string, error) {
+	return fmt.Sprintf("Video,%s,\"%s\",%d,%d", formatTimestamp(ev.StartTime), ev.Filename, ev.XOffset, ev.YOffset), nil
+}
+
+// Break is a period of the map in which there are no objects and health
+// doesn't drain.
+type Break struct {
+	StartTime Timestamp
+	EndTime   Timestamp
+}
+
+func (ev *Break) Serialize() (string, error) {
+	return fmt.Sprintf("2,%s,%s", formatTimestamp(ev.StartTime), formatTimestamp(ev.EndTime)), nil
+}
+
+// BackgroundColor is a legacy event that changes the colour shown behind the
+// playfield when there's no background image.
+type BackgroundColor struct {
+	Time  Timestamp
+	Color Color
+}
+
+func (ev *BackgroundColor) Serialize() (string, error) {
+	return fmt.Sprintf("3,%s,%s", formatTimestamp(ev.Time), ev.Color), nil
+}
+
+// Sprite is a storyboard image, animated by its commands.
+type Sprite struct {
+	Layer    StoryboardLayer
+	Origin   StoryboardOrigin
+	Filename string
+	X, Y     float64
+
+	Commands []*StoryboardCommand
+}
+
+func (ev *Sprite) Serialize() (string, error) {
+	line := fmt.Sprintf("Sprite,%s,%s,\"%s\",%s,%s",
+		STORYBOARD_LAYERS[ev.Layer],
+		STORYBOARD_ORIGINS[ev.Origin],
+		ev.Filename,
+		formatFloat(ev.X),
+		formatFloat(ev.Y),
+	)
+	return line + serializeCommands(ev.Commands, 1), nil
+}
+
+// Animation is a storyboard sprite that cycles through FrameCount images,
+// named by inserting the frame number before the extension of Filename.
+type Animation struct {
+	Sprite
+
+	FrameCount int
+	FrameDelay float64
+	LoopType   AnimationLoopType
+}
+
+func (ev *Animation) Serialize() (string, error) {
+	line := fmt.Sprintf("Animation,%s,%s,\"%s\",%s,%s,%d,%s,%s",
+		STORYBOARD_LAYERS[ev.Layer],
+		STORYBOARD_ORIGINS[ev.Origin],
+		ev.Filename,
+		formatFloat(ev.X),
+		formatFloat(ev.Y),
+		ev.FrameCount,
+		formatFloat(ev.FrameDelay),
+		ANIMATION_LOOP_TYPES[ev.LoopType],
+	)
+	return line + serializeCommands(ev.Commands, 1), nil
+}
+
+// Sample is a sound played by the storyboard.
+type Sample struct {
+	Time     Timestamp
+	Layer    StoryboardLayer
+	Filename string
+	Volume   int
+}
+
+func (ev *Sample) Serialize() (string, error) {
+	return fmt.Sprintf("Sample,%s,%d,\"%s\",%d", formatTimestamp(ev.Time), ev.Layer, ev.Filename, ev.Volume), nil
+}
+
+// UnknownEvent keeps any event we don't understand, so that it still survives
+// a round trip.
+type UnknownEvent struct {
+	Line string
+}
+
+func (ev *UnknownEvent) Serialize() (string, error) {
+	return ev.Line, nil
+}
+
+// StoryboardCommand is a single transformation applied to a storyboard object.
+// Loops (L) and triggers (T) don't have parameters of their own, but contain
+// further commands instead.
+type StoryboardCommand struct {
+	Kind      string
+	Easing    int
+	StartTime float64
+	EndTime   float64
+
+	// Params is kept as the raw fields, since their meaning (and count) depends
+	// on the kind of command
+	Params []string
+
+	LoopCount   int
+	TriggerName string
+	Commands    []*StoryboardCommand
+
+	// blankEndTime is set if the end time was left empty, so it can be left
+	// empty again when the command is written out
+	blankEndTime bool
+}
+
+// TRIGGER_NO_END_TIME is the end time of a trigger that doesn't have one,
+// which means it lasts until the end of the map.
+const TRIGGER_NO_END_TIME = math.MaxFloat64
+
+func ParseStoryboardCommand(line string) (cmd *StoryboardCommand, err error) {
+	parts := strings.Split(line, ",")
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("len(command) = %d < 3", len(parts))
+	}
+
+	cmd = &StoryboardCommand{Kind: parts[0]}
+	switch cmd.Kind {
+	case "L":
+		if cmd.StartTime, err = strconv.ParseFloat(parts[1], 64); err != nil {
+			return
+		}
+		if cmd.LoopCount, err = strconv.Atoi(parts[2]); err != nil {
+			return
+		}
+	case "T":
+		cmd.TriggerName = parts[1]
+		if cmd.StartTime, err = strconv.ParseFloat(parts[2], 64); err != nil {
+			return
+		}
+		cmd.EndTime = TRIGGER_NO_END_TIME
+		if len(parts) > 3 {
+			cmd.blankEndTime = parts[3] == ""
+			if !cmd.blankEndTime {
+				if cmd.EndTime, err = strconv.ParseFloat(parts[3], 64); err != nil {
+					return
+				}
+			}
+		}
+		// the trigger group number is optional
+		if len(parts) > 4 {
+			cmd.Params = parts[4:5]
+		}
+	default:
+		if len(parts) < 4 {
+			return nil, fmt.Errorf("len(command) = %d < 4", len(parts))
+		}
+		if cmd.Easing, err = strconv.Atoi(parts[1]); err != nil {
+			return
+		}
+		if cmd.StartTime, err = strconv.ParseFloat(parts[2], 64); err != nil {
+			return
+		}
+		// a blank end time means the command is instantaneous
+		cmd.EndTime = cmd.StartTime
+		cmd.blankEndTime = parts[3] == ""
+		if !cmd.blankEndTime {
+			if cmd.EndTime, err = strconv.ParseFloat(parts[3], 64); err != nil {
+				return
+			}
+		}
+		cmd.Params = parts[4:]
+	}
+	return
+}
+
+func (cmd *StoryboardCommand) String() string {
+	switch cmd.Kind {
+	case "L":
+		return fmt.Sprintf("L,%s,%d", formatFloat(cmd.StartTime), cmd.LoopCount)
+	case "T":
+		fields := []string{"T", cmd.TriggerName, formatFloat(cmd.StartTime)}
+		if cmd.EndTime != TRIGGER_NO_END_TIME {
+			fields = append(fields, formatFloat(cmd.EndTime))
+		} else if cmd.blankEndTime || len(cmd.Params) > 0 {
+			fields = append(fields, "")
+		}
+		return strings.Join(append(fields, cmd.Params...), ",")
+	default:
+		endTime := formatFloat(cmd.EndTime)
+		if cmd.blankEndTime && cmd.EndTime == cmd.StartTime {
+			endTime = ""
+		}
+		return strings.Join(append([]string{
+			cmd.Kind,
+			strconv.Itoa(cmd.Easing),
+			formatFloat(cmd.StartTime),
+			endTime,
+		}, cmd.Params...), ",")
+	}
+}
+
+func serializeCommands(commands []*StoryboardCommand, depth int) string {
+	var sb strings.Builder
+	for _, cmd := range commands {
+		sb.WriteString("\n")
+		sb.WriteString(strings.Repeat(" ", depth))
+		sb.WriteString(cmd.String())
+		sb.WriteString(serializeCommands(cmd.Commands, depth+1))
+	}
+	return sb.String()
+}
+
+// ParseEvent parses a single unindented line of the [Events] section.
+func ParseEvent(line string) (Event, error) {
+	parts := splitEventLine(line)
+	if len(parts) < 2 {
+		return nil, errors.New("len(parts) < 2")
+	}
+
+	switch parts[0] {
+	case "0", "Background":
+		if len(parts) < 3 {
+			return nil, errors.New("len(background) < 3")
+		}
+		ev := &Background{Filename: unquote(parts[2])}
+		if err := parseOffsets(parts[3:], &ev.XOffset, &ev.YOffset); err != nil {
+			return nil, err
+		}
+		return ev, nil
+
+	case "1", "Video":
+		if len(parts) < 3 {
+			return nil, errors.New("len(video) < 3")
+		}
+		startTime, err := parseTimestamp(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		ev := &Video{StartTime: startTime, Filename: unquote(parts[2])}
+		if err := parseOffsets(parts[3:], &ev.XOffset, &ev.YOffset); err != nil {
+			return nil, err
+		}
+		return ev, nil
+
+	case "2", "Break":
+		if len(parts) < 3 {
+			return nil, errors.New("len(break) < 3")
+		}
+		startTime, err := parseTimestamp(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		endTime, err := parseTimestamp(parts[2])
+		if err != nil {
+			return nil, err
+		}
+		return &Break{startTime, endTime}, nil
+
+	case "3", "Colour":
+		if len(parts) < 5 {
+			return nil, errors.New("len(colour) < 5")
+		}
+		time, err := parseTimestamp(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		color, err := ParseColor(strings.Join(parts[2:5], ","))
+		if err != nil {
+			return nil, err
+		}
+		return &BackgroundColor{time, color}, nil
+
+	case "4", "Sprite":
+		return parseSprite(parts)
+
+	case "5", "Sample":
+		if len(parts) < 4 {
+			return nil, errors.New("len(sample) < 4")
+		}
+		time, err := parseTimestamp(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		layer, err := parseEnum(parts[2], STORYBOARD_LAYERS_INV)
+		if err != nil {
+			return nil, err
+		}
+		volume := 100
+		if len(parts) > 4 {
+			if volume, err = strconv.Atoi(parts[4]); err != nil {
+				return nil, err
+			}
+		}
+		return &Sample{time, layer, unquote(parts[3]), volume}, nil
+
+	case "6", "Animation":
+		return parseAnimation(parts)
+
+	default:
+		return &UnknownEvent{line}, nil
+	}
+}
+
+func parseSprite(parts []string) (*Sprite, error) {
+	if len(parts) < 6 {
+		return nil, errors.New("len(sprite) < 6")
+	}
+
+	layer, err := parseEnum(parts[1], STORYBOARD_LAYERS_INV)
+	if err != nil {
+		return nil, err
+	}
+	origin, err := parseEnum(parts[2], STORYBOARD_ORIGINS_INV)
+	if err != nil {
+		return nil, err
+	}
+	x, err := strconv.ParseFloat(parts[4], 64)
+	if err != nil {
+		return nil, err
+	}
+	y, err := strconv.ParseFloat(parts[5], 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sprite{
+		Layer:    layer,
+		Origin:   origin,
+		Filename: unquote(parts[3]),
+		X:        x,
+		Y:        y,
+	}, nil
+}
+
+func parseAnimation(parts []string) (*Animation, error) {
+	if len(parts) < 8 {
+		return nil, errors.New("len(animation) < 8")
+	}
+
+	sprite, err := parseSprite(parts)
+	if err != nil {
+		return nil, err
+	}
+	frameCount, err := strconv.Atoi(parts[6])
+	if err != nil {
+		return nil, err
+	}
+	frameDelay, err := strconv.ParseFloat(parts[7], 64)
+	if err != nil {
+		return nil, err
+	}
+
+	loopType := LOOP_FOREVER
+	if len(parts) > 8 {
+		if loopType, err = parseEnum(parts[8], ANIMATION_LOOP_TYPES_INV); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Animation{
+		Sprite:     *sprite,
+		FrameCount: frameCount,
+		FrameDelay: frameDelay,
+		LoopType:   loopType,
+	}, nil
+}
+
+// addStoryboardCommand attaches a command at the given nesting depth to the
+// last storyboard object in events.
+func addStoryboardCommand(events []Event, depth int, cmd *StoryboardCommand) error {
+	if len(events) == 0 {
+		return errors.New("storyboard command without an object")
+	}
+
+	var commands *[]*StoryboardCommand
+	switch obj := events[len(events)-1].(type) {
+	case *Sprite:
+		commands = &obj.Commands
+	case *Animation:
+		commands = &obj.Commands
+	default:
+		return errors.New("storyboard command without an object")
+	}
+
+	for d := 1; d < depth; d++ {
+		if len(*commands) == 0 {
+			return errors.New("nested storyboard command without a parent")
+		}
+		commands = &(*commands)[len(*commands)-1].Commands
+	}
+
+	*commands = append(*commands, cmd)
+	return nil
+}
+
+// commandDepth counts the leading spaces or underscores, which determine how
+// deeply a storyboard command is nested.
+func commandDepth(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " _"))
+}
+
+// splitEventLine splits on commas, except for the ones inside quoted filenames.
+func splitEventLine(line string) (parts []string) {
+	quoted := false
+	start := 0
+	for i, c := range line {
+		switch c {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				parts = append(parts, line[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, line[start:])
+}
+
+func unquote(s string) string {
+	return strings.Trim(s, "\"")
+}
+
+func parseOffsets(parts []string, x, y *int) (err error) {
+	if len(parts) > 0 {
+		if *x, err = strconv.Atoi(parts[0]); err != nil {
+			return
+		}
+	}
+	if len(parts) > 1 {
+		if *y, err = strconv.Atoi(parts[1]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// parseEnum accepts either the numeric value or the (case insensitive) name.
+func parseEnum(s string, names map[string]int) (int, error) {
+	if val, err := strconv.Atoi(s); err == nil {
+		return val, nil
+	}
+	if val, ok := names[strings.ToLower(s)]; ok {
+		return val, nil
+	}
+	return 0, fmt.Errorf("unknown value '%s'", s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,129 @@
+package osu
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const eventsSection = `osu file format v14
+
+[General]
+AudioFilename: audio.mp3
+
+[Events]
+//Background and Video events
+0,0,"bg.jpg",0,0
+Video,-1850,"12.avi",0,0
+//Break Periods
+2,37004,43298
+//Storyboard Layer 0 (Background)
+Sprite,Foreground,Centre,"sb\light.png",320,240
+ F,0,1000,2000,0,1
+ L,3000,4
+  S,0,0,500,1,0.5
+ T,HitSoundClap,0,5000
+  F,0,0,100,1,0
+Animation,Foreground,Centre,"clock\c.png",320,240,10,100,LoopOnce
+ M,0,1000,1000,320,240
+Sample,500,0,"drum.wav",60
+`
+
+func TestEvents(t *testing.T) {
+	m, err := ParseBeatmap(strings.NewReader(eventsSection))
+	if err != nil {
+		t.Fatalf("failed to parse events: %v", err)
+	}
+
+	if len(m.Events) != 6 {
+		t.Fatalf("expected 6 events, got %d", len(m.Events))
+	}
+	if bg := m.Background(); bg == nil || bg.Filename != "bg.jpg" {
+		t.Errorf("wrong background: %+v", bg)
+	}
+	if breaks := m.Breaks(); len(breaks) != 1 || breaks[0].EndTime.Milliseconds() != 43298 {
+		t.Errorf("wrong breaks: %+v", breaks)
+	}
+
+	sprite, ok := m.Events[3].(*Sprite)
+	if !ok {
+		t.Fatalf("expected *Sprite, got %T", m.Events[3])
+	}
+	if len(sprite.Commands) != 3 || len(sprite.Commands[1].Commands) != 1 || sprite.Commands[1].LoopCount != 4 {
+		t.Errorf("wrong sprite commands: %+v", sprite.Commands)
+	}
+
+	animation, ok := m.Events[4].(*Animation)
+	if !ok {
+		t.Fatalf("expected *Animation, got %T", m.Events[4])
+	}
+	if animation.LoopType != LOOP_ONCE || animation.FrameCount != 10 {
+		t.Errorf("wrong animation: %+v", animation)
+	}
+
+	// everything should survive being written back out
+	var buf bytes.Buffer
+	if err = m.Serialize(&buf); err != nil {
+		t.Fatalf("failed to serialize: %v", err)
+	}
+	m2, err := ParseBeatmap(&buf)
+	if err != nil {
+		t.Fatalf("failed to reparse: %v", err)
+	}
+	for i := range m.Events {
+		before, _ := m.Events[i].Serialize()
+		after, _ := m2.Events[i].Serialize()
+		if before != after {
+			t.Errorf("event %d changed: '%s' != '%s'", i, before, after)
+		}
+	}
+}
+
+func TestStoryboardCommandTimes(t *testing.T) {
+	// times are written back exactly, and empty end times stay empty
+	for _, line := range []string{
+		"F,0,1000.5,2000.25,0,1",
+		"M,0,8410,,416,169",
+		"L,-500.5,4",
+		"T,HitSoundClap,0",
+		"T,HitSoundClap,0,,1",
+		"T,Passing,1000.5,5000",
+	} {
+		cmd, err := ParseStoryboardCommand(line)
+		if err != nil {
+			t.Errorf("failed to parse '%s': %v", line, err)
+			continue
+		}
+		if out := cmd.String(); out != line {
+			t.Errorf("'%s' was written as '%s'", line, out)
+		}
+	}
+
+	cmd, err := ParseStoryboardCommand("M,0,8410,,416,169")
+	if err != nil {
+		t.Fatalf("failed to parse command: %v", err)
+	}
+	if cmd.EndTime != 8410 {
+		t.Errorf("expected a blank end time to be the start time, got %v", cmd.EndTime)
+	}
+	cmd.EndTime = 9000
+	if out := cmd.String(); out != "M,0,8410,9000,416,169" {
+		t.Errorf("wrong command after changing the end time: '%s'", out)
+	}
+
+	cmd, err = ParseStoryboardCommand("T,HitSoundClap,0")
+	if err != nil {
+		t.Fatalf("failed to parse trigger: %v", err)
+	}
+	if cmd.EndTime != TRIGGER_NO_END_TIME {
+		t.Errorf("expected a trigger without an end time to last forever, got %v", cmd.EndTime)
+	}
+
+	ev, err := ParseEvent("2,37004.5,43298")
+	if err != nil {
+		t.Fatalf("failed to parse break: %v", err)
+	}
+	if line, _ := ev.Serialize(); line != "2,37004.5,43298" {
+		t.Errorf("wrong break: '%s'", line)
+	}
+}
@@ -0,0 +1,481 @@
+package osu
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/oklog/ulid"
+)
+
+// Operation is a single reversible change to a beatmap.
+type Operation interface {
+	Apply(m *Beatmap) error
+	Revert(m *Beatmap) error
+}
+
+type insertHitObjectOp struct {
+	obj HitObject
+}
+
+func (op insertHitObjectOp) Apply(m *Beatmap) error {
+	return m.InsertHitObject(op.obj)
+}
+
+func (op insertHitObjectOp) Revert(m *Beatmap) error {
+	_, err := m.RemoveHitObject(op.obj.GetULID())
+	return err
+}
+
+type removeHitObjectOp struct {
+	obj HitObject
+}
+
+func (op removeHitObjectOp) Apply(m *Beatmap) error {
+	_, err := m.RemoveHitObject(op.obj.GetULID())
+	return err
+}
+
+func (op removeHitObjectOp) Revert(m *Beatmap) error {
+	return m.InsertHitObject(op.obj)
+}
+
+type replaceHitObjectOp struct {
+	old, new HitObject
+}
+
+func (op replaceHitObjectOp) Apply(m *Beatmap) error {
+	return m.ReplaceHitObject(op.old.GetULID(), op.new)
+}
+
+func (op replaceHitObjectOp) Revert(m *Beatmap) error {
+	return m.ReplaceHitObject(op.new.GetULID(), op.old)
+}
+
+// timing points don't have any identity of their own, so they're tracked by
+// their position in the list instead
+type insertTimingPointOp struct {
+	index int
+	tp    TimingPoint
+}
+
+func (op insertTimingPointOp) Apply(m *Beatmap) error {
+	if op.index < 0 || op.index > len(m.TimingPoints) {
+		return fmt.Errorf("timing point index %d out of range", op.index)
+	}
+	tp := op.tp
+	timingPoints := make([]*TimingPoint, 0, len(m.TimingPoints)+1)
+	timingPoints = append(timingPoints, m.TimingPoints[:op.index]...)
+	timingPoints = append(timingPoints, &tp)
+	timingPoints = append(timingPoints, m.TimingPoints[op.index:]...)
+	return m.setTimingPoints(timingPoints)
+}
+
+func (op insertTimingPointOp) Revert(m *Beatmap) error {
+	return removeTimingPointOp{op.index, op.tp}.Apply(m)
+}
+
+type removeTimingPointOp struct {
+	index int
+	tp    TimingPoint
+}
+
+func (op removeTimingPointOp) Apply(m *Beatmap) error {
+	if op.index < 0 || op.index >= len(m.TimingPoints) {
+		return fmt.Errorf("timing point index %d out of range", op.index)
+	}
+	timingPoints := make([]*TimingPoint, 0, len(m.TimingPoints)-1)
+	timingPoints = append(timingPoints, m.TimingPoints[:op.index]...)
+	timingPoints = append(timingPoints, m.TimingPoints[op.index+1:]...)
+	return m.setTimingPoints(timingPoints)
+}
+
+func (op removeTimingPointOp) Revert(m *Beatmap) error {
+	return insertTimingPointOp{op.index, op.tp}.Apply(m)
+}
+
+type replaceTimingPointOp struct {
+	index    int
+	old, new TimingPoint
+}
+
+func (op replaceTimingPointOp) Apply(m *Beatmap) error {
+	if op.index < 0 || op.index >= len(m.TimingPoints) {
+		return fmt.Errorf("timing point index %d out of range", op.index)
+	}
+	tp := op.new
+	timingPoints := append([]*TimingPoint(nil), m.TimingPoints...)
+	timingPoints[op.index] = &tp
+	return m.setTimingPoints(timingPoints)
+}
+
+func (op replaceTimingPointOp) Revert(m *Beatmap) error {
+	return replaceTimingPointOp{op.index, op.new, op.old}.Apply(m)
+}
+
+// setTimingPoints swaps in a changed list of timing points, and gives the
+// inherited ones their new parents. Changes that would leave an inherited
+// point without an uninherited one before it are rejected.
+func (m *Beatmap) setTimingPoints(timingPoints []*TimingPoint) error {
+	if orphanedTimingPoints(timingPoints) > orphanedTimingPoints(m.TimingPoints) {
+		return errors.New("inherited timing point without an uninherited timing point before it")
+	}
+	m.TimingPoints = timingPoints
+
+	// linking only fails when orphans that were already there have nothing
+	// to link to, in which case they're left as they were
+	m.linkTimingPoints(false)
+	return nil
+}
+
+// events are tracked by their position in the list too
+type insertEventOp struct {
+	index int
+	ev    Event
+}
+
+func (op insertEventOp) Apply(m *Beatmap) error {
+	if op.index < 0 || op.index > len(m.Events) {
+		return fmt.Errorf("event index %d out of range", op.index)
+	}
+	events := make([]Event, 0, len(m.Events)+1)
+	events = append(events, m.Events[:op.index]...)
+	events = append(events, op.ev)
+	m.Events = append(events, m.Events[op.index:]...)
+	return nil
+}
+
+func (op insertEventOp) Revert(m *Beatmap) error {
+	return removeEventOp{op.index, op.ev}.Apply(m)
+}
+
+type removeEventOp struct {
+	index int
+	ev    Event
+}
+
+func (op removeEventOp) Apply(m *Beatmap) error {
+	if op.index < 0 || op.index >= len(m.Events) {
+		return fmt.Errorf("event index %d out of range", op.index)
+	}
+	events := make([]Event, 0, len(m.Events)-1)
+	events = append(events, m.Events[:op.index]...)
+	m.Events = append(events, m.Events[op.index+1:]...)
+	return nil
+}
+
+func (op removeEventOp) Revert(m *Beatmap) error {
+	return insertEventOp{op.index, op.ev}.Apply(m)
+}
+
+type replaceEventOp struct {
+	index    int
+	old, new Event
+}
+
+func (op replaceEventOp) Apply(m *Beatmap) error {
+	if op.index < 0 || op.index >= len(m.Events) {
+		return fmt.Errorf("event index %d out of range", op.index)
+	}
+	events := append([]Event(nil), m.Events...)
+	events[op.index] = op.new
+	m.Events = events
+	return nil
+}
+
+func (op replaceEventOp) Revert(m *Beatmap) error {
+	return replaceEventOp{op.index, op.new, op.old}.Apply(m)
+}
+
+// metadataOp swaps between two copies of everything in the beatmap other than
+// the events, timing points and hit objects.
+type metadataOp struct {
+	old, new Beatmap
+}
+
+// metadataOf copies everything other than the events, timing points and hit
+// objects, including what the slices and pointers refer to, so that later
+// changes to the beatmap don't change the copy.
+func metadataOf(m *Beatmap) Beatmap {
+	c := *m
+	c.Events, c.TimingPoints, c.HitObjects, c.hitObjectsByULID = nil, nil, nil, nil
+
+	c.Editor.Bookmarks = append([]int(nil), m.Editor.Bookmarks...)
+	c.Tags = append([]string(nil), m.Tags...)
+	c.Colors = append([]Color(nil), m.Colors...)
+	c.ColorNumbers = append([]int(nil), m.ColorNumbers...)
+	if m.SliderTrackOverride != nil {
+		color := *m.SliderTrackOverride
+		c.SliderTrackOverride = &color
+	}
+	if m.SliderBorder != nil {
+		color := *m.SliderBorder
+		c.SliderBorder = &color
+	}
+	if m.ExtraFields != nil {
+		c.ExtraFields = make(map[string][]Field, len(m.ExtraFields))
+		for section, fields := range m.ExtraFields {
+			c.ExtraFields[section] = append([]Field(nil), fields...)
+		}
+	}
+	if m.UnknownSections != nil {
+		c.UnknownSections = make([]Section, len(m.UnknownSections))
+		for i, section := range m.UnknownSections {
+			c.UnknownSections[i] = Section{section.Name, append([]string(nil), section.Lines...)}
+		}
+	}
+	return c
+}
+
+// restoreMetadata puts back a copy of the metadata, so that the one in the
+// history stays the same whatever happens to the beatmap afterwards.
+func restoreMetadata(m *Beatmap, metadata Beatmap) {
+	c := metadataOf(&metadata)
+	c.Events, c.TimingPoints, c.HitObjects, c.hitObjectsByULID = m.Events, m.TimingPoints, m.HitObjects, m.hitObjectsByULID
+	*m = c
+}
+
+func (op metadataOp) Apply(m *Beatmap) error {
+	restoreMetadata(m, op.new)
+	return nil
+}
+
+func (op metadataOp) Revert(m *Beatmap) error {
+	restoreMetadata(m, op.old)
+	return nil
+}
+
+// Transaction is a group of operations that are undone and redone together.
+type Transaction struct {
+	Name       string
+	Operations []Operation
+}
+
+func (tx *Transaction) apply(m *Beatmap) error {
+	for i, op := range tx.Operations {
+		if err := op.Apply(m); err != nil {
+			// put things back the way they were
+			for j := i - 1; j >= 0; j-- {
+				tx.Operations[j].Revert(m)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (tx *Transaction) revert(m *Beatmap) error {
+	for i := len(tx.Operations) - 1; i >= 0; i-- {
+		if err := tx.Operations[i].Revert(m); err != nil {
+			for j := i + 1; j < len(tx.Operations); j++ {
+				tx.Operations[j].Apply(m)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// History makes edits to a beatmap, and keeps track of them so they can be
+// undone and redone. Edits made outside of a transaction are each put in a
+// transaction of their own.
+type History struct {
+	Beatmap *Beatmap
+
+	// Limit is the most transactions that can be undone, or 0 for no limit
+	Limit int
+
+	undo    []*Transaction
+	redo    []*Transaction
+	current *Transaction
+}
+
+func NewHistory(m *Beatmap, limit int) *History {
+	return &History{Beatmap: m, Limit: limit}
+}
+
+// Begin starts a transaction, which lasts until Commit or Rollback is called.
+func (h *History) Begin(name string) error {
+	if h.current != nil {
+		return fmt.Errorf("transaction '%s' is already in progress", h.current.Name)
+	}
+	h.current = &Transaction{Name: name}
+	return nil
+}
+
+// Commit finishes the current transaction, making it available to Undo.
+func (h *History) Commit() error {
+	if h.current == nil {
+		return errors.New("no transaction in progress")
+	}
+	tx := h.current
+	h.current = nil
+	h.push(tx)
+	return nil
+}
+
+// Rollback undoes everything done in the current transaction, and discards it.
+func (h *History) Rollback() error {
+	if h.current == nil {
+		return errors.New("no transaction in progress")
+	}
+	tx := h.current
+	h.current = nil
+	return tx.revert(h.Beatmap)
+}
+
+func (h *History) push(tx *Transaction) {
+	if len(tx.Operations) == 0 {
+		return
+	}
+
+	h.undo = append(h.undo, tx)
+	if h.Limit > 0 && len(h.undo) > h.Limit {
+		h.undo = h.undo[len(h.undo)-h.Limit:]
+	}
+	h.redo = nil
+}
+
+// Do applies an operation and records it.
+func (h *History) Do(op Operation) error {
+	if err := op.Apply(h.Beatmap); err != nil {
+		return err
+	}
+
+	if h.current != nil {
+		h.current.Operations = append(h.current.Operations, op)
+	} else {
+		h.push(&Transaction{Operations: []Operation{op}})
+	}
+	return nil
+}
+
+func (h *History) CanUndo() bool {
+	return h.current == nil && len(h.undo) > 0
+}
+
+func (h *History) CanRedo() bool {
+	return h.current == nil && len(h.redo) > 0
+}
+
+// Undo reverts the most recent transaction.
+func (h *History) Undo() error {
+	if h.current != nil {
+		return errors.New("can't undo during a transaction")
+	}
+	if len(h.undo) == 0 {
+		return errors.New("nothing to undo")
+	}
+
+	tx := h.undo[len(h.undo)-1]
+	if err := tx.revert(h.Beatmap); err != nil {
+		return err
+	}
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, tx)
+	return nil
+}
+
+// Redo reapplies the most recently undone transaction.
+func (h *History) Redo() error {
+	if h.current != nil {
+		return errors.New("can't redo during a transaction")
+	}
+	if len(h.redo) == 0 {
+		return errors.New("nothing to redo")
+	}
+
+	tx := h.redo[len(h.redo)-1]
+	if err := tx.apply(h.Beatmap); err != nil {
+		return err
+	}
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, tx)
+	return nil
+}
+
+func (h *History) InsertHitObject(obj HitObject) error {
+	return h.Do(insertHitObjectOp{obj})
+}
+
+func (h *History) RemoveHitObject(id ulid.ULID) error {
+	obj, ok := h.Beatmap.HitObjectByULID(id)
+	if !ok {
+		return fmt.Errorf("no hit object with ULID %s", id)
+	}
+	return h.Do(removeHitObjectOp{obj})
+}
+
+func (h *History) ReplaceHitObject(id ulid.ULID, obj HitObject) error {
+	old, ok := h.Beatmap.HitObjectByULID(id)
+	if !ok {
+		return fmt.Errorf("no hit object with ULID %s", id)
+	}
+	return h.Do(replaceHitObjectOp{old, obj})
+}
+
+func (h *History) MoveHitObject(id ulid.ULID, startTime Timestamp, position IntPoint) error {
+	old, ok := h.Beatmap.HitObjectByULID(id)
+	if !ok {
+		return fmt.Errorf("no hit object with ULID %s", id)
+	}
+	return h.Do(replaceHitObjectOp{old, movedHitObject(old, startTime, position)})
+}
+
+// InsertTimingPoint adds a timing point after any others at the same time.
+func (h *History) InsertTimingPoint(tp TimingPoint) error {
+	time := tp.GetTimestamp().Milliseconds()
+	index := sort.Search(len(h.Beatmap.TimingPoints), func(i int) bool {
+		return (*h.Beatmap.TimingPoints[i]).GetTimestamp().Milliseconds() > time
+	})
+	return h.Do(insertTimingPointOp{index, tp})
+}
+
+func (h *History) RemoveTimingPoint(index int) error {
+	if index < 0 || index >= len(h.Beatmap.TimingPoints) {
+		return fmt.Errorf("timing point index %d out of range", index)
+	}
+	return h.Do(removeTimingPointOp{index, *h.Beatmap.TimingPoints[index]})
+}
+
+// ReplaceTimingPoint swaps out the timing point at the given index. The new
+// point should be at the same time; to move a point, remove it and insert it
+// again.
+func (h *History) ReplaceTimingPoint(index int, tp TimingPoint) error {
+	if index < 0 || index >= len(h.Beatmap.TimingPoints) {
+		return fmt.Errorf("timing point index %d out of range", index)
+	}
+	return h.Do(replaceTimingPointOp{index, *h.Beatmap.TimingPoints[index], tp})
+}
+
+// InsertEvent adds an event at the given index.
+func (h *History) InsertEvent(index int, ev Event) error {
+	return h.Do(insertEventOp{index, ev})
+}
+
+func (h *History) RemoveEvent(index int) error {
+	if index < 0 || index >= len(h.Beatmap.Events) {
+		return fmt.Errorf("event index %d out of range", index)
+	}
+	return h.Do(removeEventOp{index, h.Beatmap.Events[index]})
+}
+
+// ReplaceEvent swaps out the event at the given index. The old event is kept
+// to undo the change, so ev should be a new event rather than the old one
+// changed in place.
+func (h *History) ReplaceEvent(index int, ev Event) error {
+	if index < 0 || index >= len(h.Beatmap.Events) {
+		return fmt.Errorf("event index %d out of range", index)
+	}
+	return h.Do(replaceEventOp{index, h.Beatmap.Events[index], ev})
+}
+
+// EditMetadata records the changes edit makes to anything other than the
+// events, timing points and hit objects, such as the metadata, difficulty
+// settings or colours. edit can change slices in place, since a copy of them
+// is kept.
+func (h *History) EditMetadata(edit func(m *Beatmap)) error {
+	old := metadataOf(h.Beatmap)
+	edit(h.Beatmap)
+	return h.Do(metadataOp{old, metadataOf(h.Beatmap)})
+}
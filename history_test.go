@@ -0,0 +1,233 @@
+package osu
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	m, err := ParseBeatmap(strings.NewReader(maniaMap))
+	if err != nil {
+		t.Fatalf("failed to parse mania map: %v", err)
+	}
+	h := NewHistory(m, 2)
+
+	circle := NewCircle(NewIntPoint(320, 192), TimestampAbsolute(1500), false, 0, Extras{})
+	if err := h.InsertHitObject(circle); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+
+	// a transaction is undone all at once
+	if err := h.Begin("move and rename"); err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	if err := h.MoveHitObject(circle.GetULID(), TimestampAbsolute(3000), NewIntPoint(320, 192)); err != nil {
+		t.Fatalf("failed to move: %v", err)
+	}
+	if err := h.EditMetadata(func(m *Beatmap) { m.Title = "edited" }); err != nil {
+		t.Fatalf("failed to edit metadata: %v", err)
+	}
+	if err := h.InsertTimingPoint(UninheritedTimingPoint{BPM: 120, Meter: 4, Time: TimestampAbsolute(0)}); err != nil {
+		t.Fatalf("failed to insert timing point: %v", err)
+	}
+	if h.CanUndo() {
+		t.Error("shouldn't be able to undo during a transaction")
+	}
+	if err := h.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	if m.Title != "edited" || len(m.TimingPoints) != 1 || startTimes(m)[3] != 3000 {
+		t.Fatalf("transaction wasn't applied: %+v", m)
+	}
+
+	if err := h.Undo(); err != nil {
+		t.Fatalf("failed to undo: %v", err)
+	}
+	if m.Title != "" || len(m.TimingPoints) != 0 || startTimes(m)[2] != 1500 {
+		t.Errorf("transaction wasn't undone: %v", startTimes(m))
+	}
+
+	if err := h.Redo(); err != nil {
+		t.Fatalf("failed to redo: %v", err)
+	}
+	if m.Title != "edited" || startTimes(m)[3] != 3000 {
+		t.Errorf("transaction wasn't redone: %v", startTimes(m))
+	}
+
+	// rolling back doesn't leave anything behind
+	if err := h.Begin("remove"); err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	if err := h.RemoveHitObject(circle.GetULID()); err != nil {
+		t.Fatalf("failed to remove: %v", err)
+	}
+	if err := h.Rollback(); err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+	if len(m.HitObjects) != 4 || h.CanRedo() {
+		t.Errorf("rollback didn't restore the object")
+	}
+
+	// making a new edit clears the redo history, and only Limit transactions
+	// are kept
+	if err := h.Undo(); err != nil {
+		t.Fatalf("failed to undo: %v", err)
+	}
+	if err := h.EditMetadata(func(m *Beatmap) { m.Artist = "someone" }); err != nil {
+		t.Fatalf("failed to edit metadata: %v", err)
+	}
+	if h.CanRedo() {
+		t.Error("expected redo history to be cleared")
+	}
+	if err := h.EditMetadata(func(m *Beatmap) { m.Creator = "someone else" }); err != nil {
+		t.Fatalf("failed to edit metadata: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := h.Undo(); err != nil {
+			t.Fatalf("failed to undo: %v", err)
+		}
+	}
+	if h.CanUndo() {
+		t.Error("expected history to be limited to 2 transactions")
+	}
+	if m.Artist != "" || m.Creator != "" || len(m.HitObjects) != 4 {
+		t.Errorf("wrong state after undoing: %+v", m)
+	}
+}
+
+func TestHistoryTimingPoints(t *testing.T) {
+	slider, _ := NewSlider(NewIntPoint(0, 0), TimestampAbsolute(1000), false, 0, Extras{}, SPLINE_LINEAR, []IntPoint{NewIntPoint(100, 0)}, 1, 100)
+	m := &Beatmap{SliderMultiplier: 1, SliderTickRate: 1}
+	if err := m.InsertHitObject(slider); err != nil {
+		t.Fatalf("failed to insert slider: %v", err)
+	}
+	h := NewHistory(m, 0)
+
+	// inherited points need something to inherit from
+	inherited := InheritedTimingPoint{SvMultiplier: 2, Time: TimestampAbsolute(500)}
+	if err := h.InsertTimingPoint(inherited); err == nil {
+		t.Error("expected inserting an orphaned inherited point to fail")
+	}
+	if len(m.TimingPoints) != 0 {
+		t.Errorf("expected failed insert to leave timing points alone, got %d", len(m.TimingPoints))
+	}
+
+	if err := h.InsertTimingPoint(UninheritedTimingPoint{BPM: 120, Meter: 4, Time: TimestampAbsolute(0)}); err != nil {
+		t.Fatalf("failed to insert uninherited point: %v", err)
+	}
+	if err := h.InsertTimingPoint(inherited); err != nil {
+		t.Fatalf("failed to insert inherited point: %v", err)
+	}
+	if bpm := m.TimingPointAt(1000).GetBPM(); bpm != 120 {
+		t.Errorf("expected inserted point to inherit 120 BPM, got %v", bpm)
+	}
+	if duration := m.SliderTiming(slider).Duration; duration != 250 {
+		t.Errorf("expected slider to last 250ms, got %v", duration)
+	}
+	if err := h.RemoveTimingPoint(0); err == nil {
+		t.Error("expected removing the only uninherited point to fail")
+	}
+
+	// changing the uninherited point changes the points that inherit from it
+	if err := h.ReplaceTimingPoint(0, UninheritedTimingPoint{BPM: 60, Meter: 4, Time: TimestampAbsolute(0)}); err != nil {
+		t.Fatalf("failed to replace timing point: %v", err)
+	}
+	if bpm := m.TimingPointAt(1000).GetBPM(); bpm != 60 {
+		t.Errorf("expected inherited point to follow its parent to 60 BPM, got %v", bpm)
+	}
+	if duration := m.SliderTiming(slider).Duration; duration != 500 {
+		t.Errorf("expected slider to last 500ms, got %v", duration)
+	}
+
+	if err := h.Undo(); err != nil {
+		t.Fatalf("failed to undo: %v", err)
+	}
+	if bpm := m.TimingPointAt(1000).GetBPM(); bpm != 120 {
+		t.Errorf("expected undo to bring back 120 BPM, got %v", bpm)
+	}
+	if duration := m.SliderTiming(slider).Duration; duration != 250 {
+		t.Errorf("expected slider to last 250ms after undoing, got %v", duration)
+	}
+}
+
+func TestHistoryMetadataInPlace(t *testing.T) {
+	m := &Beatmap{
+		Colors: []Color{{255, 0, 0}},
+		Tags:   []string{"one"},
+	}
+	h := NewHistory(m, 0)
+
+	edit := func(m *Beatmap) {
+		m.Colors[0] = Color{0, 255, 0}
+		m.Tags[0] = "two"
+	}
+	if err := h.EditMetadata(edit); err != nil {
+		t.Fatalf("failed to edit metadata: %v", err)
+	}
+
+	if err := h.Undo(); err != nil {
+		t.Fatalf("failed to undo: %v", err)
+	}
+	if m.Colors[0] != (Color{255, 0, 0}) || m.Tags[0] != "one" {
+		t.Errorf("in-place changes weren't undone: %+v", m)
+	}
+
+	// changing things after undoing doesn't affect what's redone
+	m.Tags[0] = "three"
+	if err := h.Redo(); err != nil {
+		t.Fatalf("failed to redo: %v", err)
+	}
+	if m.Colors[0] != (Color{0, 255, 0}) || m.Tags[0] != "two" {
+		t.Errorf("in-place changes weren't redone: %+v", m)
+	}
+	if err := h.Undo(); err != nil {
+		t.Fatalf("failed to undo: %v", err)
+	}
+	if m.Tags[0] != "one" {
+		t.Errorf("expected the original tags after undoing again, got %v", m.Tags)
+	}
+}
+
+func TestHistoryEvents(t *testing.T) {
+	bg := &Background{Filename: "bg.jpg"}
+	m := &Beatmap{Events: []Event{bg}}
+	h := NewHistory(m, 0)
+
+	if err := h.InsertEvent(1, &Break{TimestampAbsolute(1000), TimestampAbsolute(5000)}); err != nil {
+		t.Fatalf("failed to insert event: %v", err)
+	}
+	if err := h.ReplaceEvent(0, &Background{Filename: "other.jpg"}); err != nil {
+		t.Fatalf("failed to replace event: %v", err)
+	}
+	if err := h.RemoveEvent(1); err != nil {
+		t.Fatalf("failed to remove event: %v", err)
+	}
+	if len(m.Events) != 1 || m.Background().Filename != "other.jpg" {
+		t.Errorf("wrong events after editing: %+v", m.Events)
+	}
+
+	// metadata edits leave the events alone
+	if err := h.EditMetadata(func(m *Beatmap) { m.Title = "edited" }); err != nil {
+		t.Fatalf("failed to edit metadata: %v", err)
+	}
+	if err := h.Undo(); err != nil {
+		t.Fatalf("failed to undo: %v", err)
+	}
+	if len(m.Events) != 1 || m.Background().Filename != "other.jpg" {
+		t.Errorf("undoing a metadata edit changed the events: %+v", m.Events)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := h.Undo(); err != nil {
+			t.Fatalf("failed to undo: %v", err)
+		}
+	}
+	if len(m.Events) != 1 || m.Events[0] != bg || bg.Filename != "bg.jpg" {
+		t.Errorf("wrong events after undoing: %+v", m.Events)
+	}
+
+	if err := h.RemoveEvent(5); err == nil {
+		t.Error("expected an error removing an event that doesn't exist")
+	}
+}
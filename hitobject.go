@@ -3,6 +3,7 @@ package osu
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 
@@ -17,14 +18,42 @@ type HitObject interface {
 
 	GetStartTime() Timestamp
 
+	// GetPosition returns where the object is on the playfield. Spinners are
+	// always in the middle, and for osu!mania the x coordinate decides which
+	// column the object is in.
+	GetPosition() IntPoint
+
+	GetType() HitObjectType
+
+	// IsNewCombo and GetComboSkip return whether the object starts a new
+	// combo, and how many combo colours to skip over if it does.
+	IsNewCombo() bool
+	GetComboSkip() int
+
+	GetHitsound() Hitsound
+	GetExtras() Extras
+
 	Serialize() (string, error)
 }
 
+type HitObjectType = int
+
+const (
+	OBJ_CIRCLE    = 1
+	OBJ_SLIDER    = 2
+	OBJ_SPINNER   = 8
+	OBJ_HOLD_NOTE = 128
+)
+
+// the middle of the playfield, where spinners are placed
+var PLAYFIELD_CENTER = IntPoint{256, 192}
+
 type ObjCircle struct {
 	ulid      ulid.ULID
 	x, y      int
 	startTime Timestamp
 	newCombo  bool
+	comboSkip int
 	additions Hitsound
 	extras    *Extras
 }
@@ -45,12 +74,26 @@ func ParseHitCircle(params commonParameters, parts []string) (obj ObjCircle, err
 		y:         params.y,
 		startTime: TimestampAbsolute(params.startTime),
 		newCombo:  params.newCombo,
+		comboSkip: params.comboSkip,
 		additions: Hitsound(params.hitsound),
 		extras:    extras,
 	}
 	return
 }
 
+// NewCircle creates a hit circle with a new ULID.
+func NewCircle(position IntPoint, startTime Timestamp, newCombo bool, hitsound Hitsound, extras Extras) ObjCircle {
+	return ObjCircle{
+		ulid:      NewULID(),
+		x:         position.x,
+		y:         position.y,
+		startTime: startTime,
+		newCombo:  newCombo,
+		additions: hitsound,
+		extras:    &extras,
+	}
+}
+
 func (obj ObjCircle) GetULID() ulid.ULID {
 	return obj.ulid
 }
@@ -59,12 +102,42 @@ func (obj ObjCircle) GetStartTime() Timestamp {
 	return obj.startTime
 }
 
+// GetEndTime returns the same time as GetStartTime, since circles don't have
+// any length.
+func (obj ObjCircle) GetEndTime() Timestamp {
+	return obj.startTime
+}
+
+func (obj ObjCircle) GetPosition() IntPoint {
+	return IntPoint{obj.x, obj.y}
+}
+
+func (obj ObjCircle) GetType() HitObjectType {
+	return OBJ_CIRCLE
+}
+
+func (obj ObjCircle) IsNewCombo() bool {
+	return obj.newCombo
+}
+
+func (obj ObjCircle) GetComboSkip() int {
+	return obj.comboSkip
+}
+
+func (obj ObjCircle) GetHitsound() Hitsound {
+	return obj.additions
+}
+
+func (obj ObjCircle) GetExtras() Extras {
+	return *obj.extras
+}
+
 func (obj ObjCircle) Serialize() (string, error) {
 	return fmt.Sprintf("%d,%d,%d,%d,%d,%s",
 		obj.x,
 		obj.y,
-		obj.startTime,
-		1|(WHAT_THE_FUCK[obj.newCombo]<<2),
+		obj.startTime.Milliseconds(),
+		OBJ_CIRCLE|(WHAT_THE_FUCK[obj.newCombo]<<2)|(obj.comboSkip<<4),
 		obj.additions,
 		obj.extras.String(),
 	), nil
@@ -75,35 +148,66 @@ type ObjSlider struct {
 	x, y      int
 	startTime Timestamp
 	newCombo  bool
+	comboSkip int
 	additions Hitsound
 	extras    *Extras
 
-	splineKind    SplineKind
-	ctlPoints     []IntPoint
-	spline        []FloatPoint
+	splineKind  SplineKind
+	ctlPoints   []IntPoint
+	spline      []FloatPoint
+	lengths     []float64
+	pixelLength float64
+
+	// repeatCount is the number of times the slider is traversed, so it's one
+	// more than the number of repeat arrows. the edge slices have an entry for
+	// each end of each traversal, or are nil if the map doesn't have them.
 	repeatCount   int
-	pixelLength   float64
 	edgeHitsounds []Hitsound
-	edgeAdditions []Hitsound
+	edgeAdditions []EdgeSet
 }
 
 func ParseSlider(params commonParameters, parts []string) (obj ObjSlider, err error) {
 	var (
-		pixelLength float64
-		extras      *Extras = &Extras{}
+		pixelLength   float64
+		edgeHitsounds []Hitsound
+		edgeAdditions []EdgeSet
+		extras        *Extras = &Extras{}
 	)
 
-	// if len(parts) < 11 {
-	// 	return ObjSlider{}, fmt.Errorf("len(slider) = %d < 11", len(parts))
-	// }
-	// extras, err := ParseExtras(parts[10])
-	// if err != nil {
-	// 	return ObjSlider{}, err
-	// }
+	if len(parts) < 7 {
+		return ObjSlider{}, fmt.Errorf("len(slider) = %d < 7", len(parts))
+	}
+
+	kind, ctlPoints, err := ParseControlPoints(parts[5])
+	if err != nil {
+		return
+	}
+	ctlPoints = append([]IntPoint{IntPoint{params.x, params.y}}, ctlPoints...)
+
+	repeatCount, err := strconv.Atoi(parts[6])
+	if err != nil {
+		return
+	}
 
 	if len(parts) > 7 {
 		// pixelLength
-		pixelLength, err = strconv.ParseFloat(parts[3], 64)
+		pixelLength, err = strconv.ParseFloat(parts[7], 64)
+		if err != nil {
+			return
+		}
+	}
+
+	if len(parts) > 8 {
+		// edgeSounds
+		edgeHitsounds, err = ParseEdgeHitsounds(parts[8])
+		if err != nil {
+			return
+		}
+	}
+
+	if len(parts) > 9 {
+		// edgeSets
+		edgeAdditions, err = ParseEdgeSets(parts[9])
 		if err != nil {
 			return
 		}
@@ -117,9 +221,20 @@ func ParseSlider(params commonParameters, parts []string) (obj ObjSlider, err er
 		}
 	}
 
-	kind, ctlPoints, err := ParseControlPoints(parts[5])
-	ctlPoints = append([]IntPoint{IntPoint{params.x, params.y}}, ctlPoints...)
-	spline, err := SplineFrom(kind, ctlPoints, pixelLength)
+	// there's a hitsound for the head, the tail, and every repeat in between
+	if repeatCount < 1 {
+		return ObjSlider{}, fmt.Errorf("slider must have at least 1 slide, got %d", repeatCount)
+	}
+	if edgeHitsounds != nil && len(edgeHitsounds) != repeatCount+1 {
+		return ObjSlider{}, fmt.Errorf("len(edgeSounds) = %d, expected %d", len(edgeHitsounds), repeatCount+1)
+	}
+	if edgeAdditions != nil && len(edgeAdditions) != repeatCount+1 {
+		return ObjSlider{}, fmt.Errorf("len(edgeSets) = %d, expected %d", len(edgeAdditions), repeatCount+1)
+	}
+
+	// the path is derived from the control points, so even if we can't compute
+	// it the slider is still usable, and can be written back out unchanged
+	spline, _ := SplineFrom(kind, ctlPoints, pixelLength)
 
 	obj = ObjSlider{
 		ulid:      NewULID(),
@@ -127,16 +242,54 @@ func ParseSlider(params commonParameters, parts []string) (obj ObjSlider, err er
 		y:         params.y,
 		startTime: TimestampAbsolute(params.startTime),
 		newCombo:  params.newCombo,
+		comboSkip: params.comboSkip,
 		additions: Hitsound(params.hitsound),
 		extras:    extras,
 
-		spline:      spline,
-		splineKind:  kind,
-		pixelLength: pixelLength,
+		splineKind:    kind,
+		ctlPoints:     ctlPoints,
+		spline:        spline,
+		lengths:       splineLengths(spline),
+		repeatCount:   repeatCount,
+		pixelLength:   pixelLength,
+		edgeHitsounds: edgeHitsounds,
+		edgeAdditions: edgeAdditions,
 	}
 	return
 }
 
+// NewSlider creates a slider with a new ULID. The control points don't include
+// the head of the slider, which is at position. Unlike parsing, this fails if
+// the path can't be computed.
+func NewSlider(position IntPoint, startTime Timestamp, newCombo bool, hitsound Hitsound, extras Extras, kind SplineKind, ctlPoints []IntPoint, slides int, pixelLength float64) (ObjSlider, error) {
+	if slides < 1 {
+		return ObjSlider{}, fmt.Errorf("slider must have at least 1 slide, got %d", slides)
+	}
+
+	points := append([]IntPoint{position}, ctlPoints...)
+	spline, err := SplineFrom(kind, points, pixelLength)
+	if err != nil {
+		return ObjSlider{}, err
+	}
+
+	return ObjSlider{
+		ulid:      NewULID(),
+		x:         position.x,
+		y:         position.y,
+		startTime: startTime,
+		newCombo:  newCombo,
+		additions: hitsound,
+		extras:    &extras,
+
+		splineKind:  kind,
+		ctlPoints:   points,
+		spline:      spline,
+		lengths:     splineLengths(spline),
+		repeatCount: slides,
+		pixelLength: pixelLength,
+	}, nil
+}
+
 func (obj ObjSlider) GetULID() ulid.ULID {
 	return obj.ulid
 }
@@ -145,21 +298,121 @@ func (obj ObjSlider) GetStartTime() Timestamp {
 	return obj.startTime
 }
 
+func (obj ObjSlider) GetPosition() IntPoint {
+	return IntPoint{obj.x, obj.y}
+}
+
+func (obj ObjSlider) GetType() HitObjectType {
+	return OBJ_SLIDER
+}
+
+func (obj ObjSlider) IsNewCombo() bool {
+	return obj.newCombo
+}
+
+func (obj ObjSlider) GetComboSkip() int {
+	return obj.comboSkip
+}
+
+func (obj ObjSlider) GetHitsound() Hitsound {
+	return obj.additions
+}
+
+func (obj ObjSlider) GetExtras() Extras {
+	return *obj.extras
+}
+
+func (obj ObjSlider) GetSplineKind() SplineKind {
+	return obj.splineKind
+}
+
+// GetControlPoints returns the points that define the shape of the slider,
+// starting with its head.
+func (obj ObjSlider) GetControlPoints() []IntPoint {
+	return append([]IntPoint(nil), obj.ctlPoints...)
+}
+
+// GetPixelLength returns the length of the slider as written in the map,
+// which the path is cut or extended to match.
+func (obj ObjSlider) GetPixelLength() float64 {
+	return obj.pixelLength
+}
+
+// Slides returns the number of times the path is traversed.
+func (obj ObjSlider) Slides() int {
+	return obj.repeatCount
+}
+
+// Repeats returns the number of times the slider reverses direction.
+func (obj ObjSlider) Repeats() int {
+	return obj.repeatCount - 1
+}
+
+// EdgeHitsounds returns the hitsound played at the head, each repeat, and the
+// tail. Older maps don't specify these, in which case every edge uses the
+// hitsound of the slider itself.
+func (obj ObjSlider) EdgeHitsounds() []Hitsound {
+	hitsounds := make([]Hitsound, obj.repeatCount+1)
+	for i := range hitsounds {
+		if i < len(obj.edgeHitsounds) {
+			hitsounds[i] = obj.edgeHitsounds[i]
+		} else {
+			hitsounds[i] = obj.additions
+		}
+	}
+	return hitsounds
+}
+
+// EdgeSets returns the sample sets used at the head, each repeat, and the
+// tail. Older maps don't specify these, in which case every edge uses the
+// sample sets from the slider's extras.
+func (obj ObjSlider) EdgeSets() []EdgeSet {
+	sets := make([]EdgeSet, obj.repeatCount+1)
+	for i := range sets {
+		if i < len(obj.edgeAdditions) {
+			sets[i] = obj.edgeAdditions[i]
+		} else {
+			sets[i] = EdgeSet{obj.extras.SampleSet, obj.extras.AdditionSet}
+		}
+	}
+	return sets
+}
+
 func (obj ObjSlider) Serialize() (string, error) {
-	return fmt.Sprintf("%d,%d,%d,%d,%d,%c|%s,%d,%f,%s,%s,%s",
+	line := fmt.Sprintf("%d,%d,%d,%d,%d,%s,%d,%s",
 		obj.x,
 		obj.y,
-		obj.startTime,
-		1|(WHAT_THE_FUCK[obj.newCombo]<<2),
+		obj.startTime.Milliseconds(),
+		OBJ_SLIDER|(WHAT_THE_FUCK[obj.newCombo]<<2)|(obj.comboSkip<<4),
 		obj.additions,
-		obj.splineKind,
-		"TODO",
+		SerializeControlPoints(obj.splineKind, obj.ctlPoints[1:]),
 		obj.repeatCount,
-		obj.pixelLength,
-		"TODO",
-		"TODO",
-		obj.extras.String(),
-	), nil
+		strconv.FormatFloat(obj.pixelLength, 'f', -1, 64),
+	)
+
+	// older maps are missing some or all of the hitsound fields, so only write
+	// out as many as we need to
+	hasExtras := *obj.extras != (Extras{})
+	hasEdgeSets := obj.edgeAdditions != nil || hasExtras
+	hasEdgeHitsounds := obj.edgeHitsounds != nil || hasEdgeSets
+
+	if hasEdgeHitsounds {
+		edgeHitsounds := obj.edgeHitsounds
+		if edgeHitsounds == nil {
+			edgeHitsounds = make([]Hitsound, obj.repeatCount+1)
+		}
+		line += "," + SerializeEdgeHitsounds(edgeHitsounds)
+	}
+	if hasEdgeSets {
+		edgeAdditions := obj.edgeAdditions
+		if edgeAdditions == nil {
+			edgeAdditions = make([]EdgeSet, obj.repeatCount+1)
+		}
+		line += "," + SerializeEdgeSets(edgeAdditions)
+		line += "," + obj.extras.String()
+	}
+
+	return line, nil
 }
 
 type ObjSpinner struct {
@@ -168,19 +421,28 @@ type ObjSpinner struct {
 	startTime Timestamp
 	endTime   Timestamp
 	newCombo  bool
+	comboSkip int
 	additions Hitsound
 	extras    *Extras
 }
 
 func ParseSpinner(params commonParameters, parts []string) (obj ObjSpinner, err error) {
+	var extras *Extras = &Extras{}
+
+	if len(parts) < 6 {
+		return ObjSpinner{}, fmt.Errorf("len(spinner) = %d < 6", len(parts))
+	}
+
 	endTime, err := strconv.Atoi(parts[5])
 	if err != nil {
 		return
 	}
 
-	extras, err := ParseExtras(parts[6])
-	if err != nil {
-		return
+	if len(parts) > 6 {
+		extras, err = ParseExtras(parts[6])
+		if err != nil {
+			return
+		}
 	}
 
 	obj = ObjSpinner{
@@ -190,12 +452,27 @@ func ParseSpinner(params commonParameters, parts []string) (obj ObjSpinner, err
 		startTime: TimestampAbsolute(params.startTime),
 		endTime:   TimestampAbsolute(endTime),
 		newCombo:  params.newCombo,
+		comboSkip: params.comboSkip,
 		additions: params.hitsound,
 		extras:    extras,
 	}
 	return
 }
 
+// NewSpinner creates a spinner with a new ULID, in the middle of the playfield.
+func NewSpinner(startTime, endTime Timestamp, newCombo bool, hitsound Hitsound, extras Extras) ObjSpinner {
+	return ObjSpinner{
+		ulid:      NewULID(),
+		x:         PLAYFIELD_CENTER.x,
+		y:         PLAYFIELD_CENTER.y,
+		startTime: startTime,
+		endTime:   endTime,
+		newCombo:  newCombo,
+		additions: hitsound,
+		extras:    &extras,
+	}
+}
+
 func (obj ObjSpinner) GetULID() ulid.ULID {
 	return obj.ulid
 }
@@ -204,22 +481,192 @@ func (obj ObjSpinner) GetStartTime() Timestamp {
 	return obj.startTime
 }
 
+func (obj ObjSpinner) GetEndTime() Timestamp {
+	return obj.endTime
+}
+
+func (obj ObjSpinner) GetPosition() IntPoint {
+	return IntPoint{obj.x, obj.y}
+}
+
+func (obj ObjSpinner) GetType() HitObjectType {
+	return OBJ_SPINNER
+}
+
+func (obj ObjSpinner) IsNewCombo() bool {
+	return obj.newCombo
+}
+
+func (obj ObjSpinner) GetComboSkip() int {
+	return obj.comboSkip
+}
+
+func (obj ObjSpinner) GetHitsound() Hitsound {
+	return obj.additions
+}
+
+func (obj ObjSpinner) GetExtras() Extras {
+	return *obj.extras
+}
+
 func (obj ObjSpinner) Serialize() (string, error) {
 	return fmt.Sprintf("%d,%d,%d,%d,%d,%d,%s",
 		obj.x,
 		obj.y,
-		obj.startTime,
-		8|(WHAT_THE_FUCK[obj.newCombo]<<2),
+		obj.startTime.Milliseconds(),
+		OBJ_SPINNER|(WHAT_THE_FUCK[obj.newCombo]<<2)|(obj.comboSkip<<4),
+		obj.additions,
+		obj.endTime.Milliseconds(),
+		obj.extras.String(),
+	), nil
+}
+
+// ObjHoldNote is an osu!mania note that has to be held down until endTime.
+type ObjHoldNote struct {
+	ulid      ulid.ULID
+	x, y      int
+	startTime Timestamp
+	endTime   Timestamp
+	newCombo  bool
+	comboSkip int
+	additions Hitsound
+	extras    *Extras
+}
+
+func ParseHoldNote(params commonParameters, parts []string) (obj ObjHoldNote, err error) {
+	var extras *Extras = &Extras{}
+
+	if len(parts) < 6 {
+		return ObjHoldNote{}, fmt.Errorf("len(holdnote) = %d < 6", len(parts))
+	}
+
+	// the end time is stuck on the front of the extras, instead of being a
+	// separate field
+	fields := strings.SplitN(parts[5], ":", 2)
+	endTime, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return
+	}
+
+	if len(fields) > 1 {
+		extras, err = ParseExtras(fields[1])
+		if err != nil {
+			return
+		}
+	}
+
+	obj = ObjHoldNote{
+		ulid:      NewULID(),
+		x:         params.x,
+		y:         params.y,
+		startTime: TimestampAbsolute(params.startTime),
+		endTime:   TimestampAbsolute(endTime),
+		newCombo:  params.newCombo,
+		comboSkip: params.comboSkip,
+		additions: params.hitsound,
+		extras:    extras,
+	}
+	return
+}
+
+// NewHoldNote creates an osu!mania hold note with a new ULID. Use ColumnX to
+// find the position for a column.
+func NewHoldNote(position IntPoint, startTime, endTime Timestamp, hitsound Hitsound, extras Extras) ObjHoldNote {
+	return ObjHoldNote{
+		ulid:      NewULID(),
+		x:         position.x,
+		y:         position.y,
+		startTime: startTime,
+		endTime:   endTime,
+		additions: hitsound,
+		extras:    &extras,
+	}
+}
+
+func (obj ObjHoldNote) GetULID() ulid.ULID {
+	return obj.ulid
+}
+
+func (obj ObjHoldNote) GetStartTime() Timestamp {
+	return obj.startTime
+}
+
+func (obj ObjHoldNote) GetEndTime() Timestamp {
+	return obj.endTime
+}
+
+func (obj ObjHoldNote) GetPosition() IntPoint {
+	return IntPoint{obj.x, obj.y}
+}
+
+func (obj ObjHoldNote) GetType() HitObjectType {
+	return OBJ_HOLD_NOTE
+}
+
+func (obj ObjHoldNote) IsNewCombo() bool {
+	return obj.newCombo
+}
+
+func (obj ObjHoldNote) GetComboSkip() int {
+	return obj.comboSkip
+}
+
+func (obj ObjHoldNote) GetHitsound() Hitsound {
+	return obj.additions
+}
+
+func (obj ObjHoldNote) GetExtras() Extras {
+	return *obj.extras
+}
+
+// Column returns the column the note is in, for a map with the given number
+// of keys.
+func (obj ObjHoldNote) Column(keyCount int) int {
+	return ManiaColumn(obj.x, keyCount)
+}
+
+func (obj ObjHoldNote) Serialize() (string, error) {
+	return fmt.Sprintf("%d,%d,%d,%d,%d,%d:%s",
+		obj.x,
+		obj.y,
+		obj.startTime.Milliseconds(),
+		OBJ_HOLD_NOTE|(WHAT_THE_FUCK[obj.newCombo]<<2)|(obj.comboSkip<<4),
 		obj.additions,
-		obj.endTime,
+		obj.endTime.Milliseconds(),
 		obj.extras.String(),
 	), nil
 }
 
+// ManiaColumn returns the osu!mania column that an object at the given x
+// position falls in, for a map with the given number of keys.
+func ManiaColumn(x int, keyCount int) int {
+	if keyCount < 1 {
+		return 0
+	}
+
+	column := int(math.Floor(float64(x) * float64(keyCount) / 512))
+	if column < 0 {
+		return 0
+	} else if column >= keyCount {
+		return keyCount - 1
+	}
+	return column
+}
+
+// ColumnX returns the x position in the middle of an osu!mania column, for a
+// map with the given number of keys.
+func ColumnX(column int, keyCount int) int {
+	if keyCount < 1 {
+		return 0
+	}
+	return int(math.Floor((float64(column) + 0.5) * 512 / float64(keyCount)))
+}
+
 type commonParameters struct {
 	x, y      int
 	startTime int
 	newCombo  bool
+	comboSkip int
 	hitsound  int
 }
 
@@ -249,21 +696,25 @@ func ParseHitObject(line string) (HitObject, error) {
 		return nil, err
 	}
 
-	hitsound, err := strconv.Atoi(parts[3])
+	hitsound, err := strconv.Atoi(parts[4])
 	if err != nil {
 		return nil, err
 	}
 
+	// bits 4-6 are the number of combo colours to skip over
 	newCombo := (ty & 4) > 0
-	params := commonParameters{x, y, startTime, newCombo, hitsound}
+	comboSkip := (ty >> 4) & 7
+	params := commonParameters{x, y, startTime, newCombo, comboSkip, hitsound}
 
 	switch {
-	case (ty & 1) > 0:
+	case (ty & OBJ_CIRCLE) > 0:
 		return ParseHitCircle(params, parts)
-	case (ty & 2) > 0:
+	case (ty & OBJ_SLIDER) > 0:
 		return ParseSlider(params, parts)
-	case (ty & 8) > 0:
+	case (ty & OBJ_SPINNER) > 0:
 		return ParseSpinner(params, parts)
+	case (ty & OBJ_HOLD_NOTE) > 0:
+		return ParseHoldNote(params, parts)
 	default:
 		return nil, fmt.Errorf("unknown hitobject type: %+v", ty)
 	}
@@ -0,0 +1,101 @@
+package osu
+
+import (
+	"strings"
+	"testing"
+)
+
+const maniaMap = `osu file format v14
+
+[General]
+Mode: 3
+
+[Difficulty]
+CircleSize:4
+
+[HitObjects]
+64,192,1000,1,0,0:0:0:0:
+192,192,1000,128,0,1500:0:0:0:0:
+448,192,2000,128,2,2750:1:2:0:80:
+`
+
+func TestHoldNote(t *testing.T) {
+	m, err := ParseBeatmap(strings.NewReader(maniaMap))
+	if err != nil {
+		t.Fatalf("failed to parse mania map: %v", err)
+	}
+	if m.KeyCount() != 4 {
+		t.Errorf("expected 4 keys, got %d", m.KeyCount())
+	}
+
+	note, ok := (*m.HitObjects[2]).(ObjHoldNote)
+	if !ok {
+		t.Fatalf("expected ObjHoldNote, got %T", *m.HitObjects[2])
+	}
+	if note.GetEndTime().Milliseconds() != 2750 || note.extras.SampleVolume != 80 {
+		t.Errorf("wrong hold note: %+v", note)
+	}
+	if note.Column(m.KeyCount()) != 3 {
+		t.Errorf("expected column 3, got %d", note.Column(m.KeyCount()))
+	}
+	if line, _ := note.Serialize(); line != "448,192,2000,128,2,2750:1:2:0:80:" {
+		t.Errorf("wrong serialization: '%s'", line)
+	}
+
+	columns := []int{0, 1, 3}
+	for i, x := range []int{64, 192, 448} {
+		if c := ManiaColumn(x, m.KeyCount()); c != columns[i] {
+			t.Errorf("expected x = %d to be in column %d, got %d", x, columns[i], c)
+		}
+	}
+}
+
+func TestNewHitObjects(t *testing.T) {
+	extras := Extras{SampleSet: SAMPLE_SOFT}
+
+	circle := NewCircle(NewIntPoint(100, 200), TimestampAbsolute(500), true, HITSOUND_WHISTLE, extras)
+	if line, _ := circle.Serialize(); line != "100,200,500,5,2,2:0:0:0:" {
+		t.Errorf("wrong circle: '%s'", line)
+	}
+
+	slider, err := NewSlider(NewIntPoint(0, 0), TimestampAbsolute(1000), false, 0, Extras{}, SPLINE_LINEAR, []IntPoint{NewIntPoint(100, 0)}, 2, 100)
+	if err != nil {
+		t.Fatalf("failed to create slider: %v", err)
+	}
+	if line, _ := slider.Serialize(); line != "0,0,1000,2,0,L|100:0,2,100" {
+		t.Errorf("wrong slider: '%s'", line)
+	}
+	if slider.GetType() != OBJ_SLIDER || len(slider.GetControlPoints()) != 2 || slider.Length() != 100 {
+		t.Errorf("wrong slider: %+v", slider)
+	}
+	if _, err := NewSlider(NewIntPoint(0, 0), TimestampAbsolute(0), false, 0, Extras{}, SPLINE_LINEAR, nil, 1, 100); err == nil {
+		t.Error("expected an error for a slider without a path")
+	}
+
+	spinner := NewSpinner(TimestampAbsolute(2000), TimestampAbsolute(3000), false, 0, Extras{})
+	if spinner.GetPosition() != PLAYFIELD_CENTER || spinner.GetEndTime().Milliseconds() != 3000 {
+		t.Errorf("wrong spinner: %+v", spinner)
+	}
+
+	note := NewHoldNote(NewIntPoint(ColumnX(2, 4), 192), TimestampAbsolute(0), TimestampAbsolute(100), 0, Extras{})
+	if note.Column(4) != 2 {
+		t.Errorf("expected column 2, got %d", note.Column(4))
+	}
+
+	if circle.GetULID() == slider.GetULID() {
+		t.Error("expected different ULIDs")
+	}
+
+	// the extras are copied, so changing them doesn't change the object
+	extras.SampleSet = SAMPLE_DRUM
+	if circle.GetExtras().SampleSet != SAMPLE_SOFT {
+		t.Error("expected extras to be copied")
+	}
+
+	m := &Beatmap{SliderMultiplier: 1, SliderTickRate: 1}
+	var tp TimingPoint = UninheritedTimingPoint{BPM: 60, Meter: 4, Time: TimestampAbsolute(0)}
+	m.TimingPoints = []*TimingPoint{&tp}
+	if end := m.EndTime(slider).Milliseconds(); end != 3000 {
+		t.Errorf("expected slider to end at 3000, got %d", end)
+	}
+}
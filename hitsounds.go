@@ -88,3 +88,62 @@ func (extras Extras) String() string {
 		extras.Filename,
 	)
 }
+
+// EdgeSet describes the sample sets used for the hitsounds on one edge (the
+// head, a repeat or the tail) of a slider.
+type EdgeSet struct {
+	SampleSet   SampleSet
+	AdditionSet SampleSet
+}
+
+func ParseEdgeHitsounds(line string) (hitsounds []Hitsound, err error) {
+	for _, s := range strings.Split(line, "|") {
+		var hitsound int
+		hitsound, err = strconv.Atoi(s)
+		if err != nil {
+			return nil, err
+		}
+		hitsounds = append(hitsounds, Hitsound(hitsound))
+	}
+	return
+}
+
+func SerializeEdgeHitsounds(hitsounds []Hitsound) string {
+	parts := make([]string, len(hitsounds))
+	for i, hitsound := range hitsounds {
+		parts[i] = strconv.Itoa(hitsound)
+	}
+	return strings.Join(parts, "|")
+}
+
+func ParseEdgeSets(line string) (sets []EdgeSet, err error) {
+	for _, s := range strings.Split(line, "|") {
+		var sampleSet, additionSet int
+
+		pair := strings.Split(s, ":")
+		if len(pair) < 2 {
+			return nil, fmt.Errorf("len(edgeSet) = %d < 2", len(pair))
+		}
+
+		sampleSet, err = strconv.Atoi(pair[0])
+		if err != nil {
+			return nil, err
+		}
+
+		additionSet, err = strconv.Atoi(pair[1])
+		if err != nil {
+			return nil, err
+		}
+
+		sets = append(sets, EdgeSet{sampleSet, additionSet})
+	}
+	return
+}
+
+func SerializeEdgeSets(sets []EdgeSet) string {
+	parts := make([]string, len(sets))
+	for i, set := range sets {
+		parts[i] = fmt.Sprintf("%d:%d", set.SampleSet, set.AdditionSet)
+	}
+	return strings.Join(parts, "|")
+}
@@ -0,0 +1,298 @@
+package osu
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+type ManiaJudgement = int
+
+const (
+	MANIA_MISS = 0
+	MANIA_50   = 1
+	MANIA_100  = 2
+	MANIA_200  = 3
+	MANIA_300  = 4
+	MANIA_MAX  = 5
+)
+
+// how much later than usual a hold note can be released
+const MANIA_RELEASE_LENIENCE = 1.5
+
+// the most keys that fit in one stage, any more and the map has two
+const MANIA_MAX_STAGE_KEYS = 10
+
+// ReplayFrame is the state of the keys at a point in a replay. For osu!mania,
+// bit i of Keys is set while the key for column i is held down.
+type ReplayFrame struct {
+	Time int
+	Keys int
+}
+
+// ManiaHitWindows are the maximum offsets, in milliseconds either side of a
+// note, that get each judgement.
+type ManiaHitWindows struct {
+	Max, Great, Good, Ok, Meh, Miss float64
+}
+
+func NewManiaHitWindows(overallDifficulty float64) ManiaHitWindows {
+	return ManiaHitWindows{
+		Max:   16,
+		Great: 64 - 3*overallDifficulty,
+		Good:  97 - 3*overallDifficulty,
+		Ok:    127 - 3*overallDifficulty,
+		Meh:   151 - 3*overallDifficulty,
+		Miss:  188 - 3*overallDifficulty,
+	}
+}
+
+// JudgementFor returns the judgement for hitting a note offset milliseconds
+// away from when it should be hit.
+func (w ManiaHitWindows) JudgementFor(offset float64) ManiaJudgement {
+	offset = math.Abs(offset)
+	switch {
+	case offset <= w.Max:
+		return MANIA_MAX
+	case offset <= w.Great:
+		return MANIA_300
+	case offset <= w.Good:
+		return MANIA_200
+	case offset <= w.Ok:
+		return MANIA_100
+	case offset <= w.Meh:
+		return MANIA_50
+	default:
+		return MANIA_MISS
+	}
+}
+
+// ManiaNoteResult is the judgement given to a note, or to the head or tail of
+// a hold note.
+type ManiaNoteResult struct {
+	// ObjectIndex is the index of the object in Beatmap.HitObjects
+	ObjectIndex int
+	Column      int
+	Tail        bool
+
+	Judgement ManiaJudgement
+
+	// Time is when the judgement happened, and Offset is how far that was
+	// from the note. Offset is meaningless for misses.
+	Time   float64
+	Offset float64
+}
+
+type ManiaResult struct {
+	// Results are in the order they were judged
+	Results []ManiaNoteResult
+
+	// Counts has the number of each judgement, indexed by ManiaJudgement
+	Counts [6]int
+
+	Combo     int
+	MaxCombo  int
+	Accuracy  float64
+	GhostTaps int
+}
+
+// ManiaNote is a note or hold note in an osu!mania map.
+type ManiaNote struct {
+	// ObjectIndex is the index of the object in Beatmap.HitObjects
+	ObjectIndex int
+	Column      int
+
+	// EndTime is the same as StartTime for notes that aren't held
+	StartTime float64
+	EndTime   float64
+	Hold      bool
+}
+
+// ManiaNotes returns the notes of an osu!mania map in order of start time.
+// Sliders and spinners can't be played in osu!mania, so they're skipped.
+func (m *Beatmap) ManiaNotes() (notes []ManiaNote) {
+	keyCount := m.KeyCount()
+	for i, obj := range m.HitObjects {
+		switch o := (*obj).(type) {
+		case ObjCircle:
+			time := float64(o.startTime.Milliseconds())
+			notes = append(notes, ManiaNote{ObjectIndex: i, Column: ManiaColumn(o.x, keyCount), StartTime: time, EndTime: time})
+		case ObjHoldNote:
+			notes = append(notes, ManiaNote{
+				ObjectIndex: i,
+				Column:      o.Column(keyCount),
+				StartTime:   float64(o.startTime.Milliseconds()),
+				EndTime:     float64(o.endTime.Milliseconds()),
+				Hold:        true,
+			})
+		}
+	}
+
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].StartTime < notes[j].StartTime })
+	return
+}
+
+// ManiaColumns splits the notes of an osu!mania map up by column, with each
+// column in order of start time.
+func (m *Beatmap) ManiaColumns() [][]ManiaNote {
+	columns := make([][]ManiaNote, m.KeyCount())
+	for _, note := range m.ManiaNotes() {
+		columns[note.Column] = append(columns[note.Column], note)
+	}
+	return columns
+}
+
+type keyEvent struct {
+	time    float64
+	pressed bool
+}
+
+// SimulateMania judges a replay of an osu!mania map.
+//
+// Each press goes to the earliest unjudged note in its column, as long as it's
+// within the miss window; pressing any earlier than that is a ghost tap, which
+// is counted but otherwise ignored. Notes that haven't been hit by the end of
+// the 50 window are missed. The tail of a hold note is judged on release with
+// windows MANIA_RELEASE_LENIENCE times as large, releasing any earlier than
+// that (or missing the head) misses the tail, and holding past the end of the
+// window gets a 50.
+func SimulateMania(m *Beatmap, frames []ReplayFrame) (*ManiaResult, error) {
+	if m.Mode != MODE_MANIA {
+		return nil, errors.New("beatmap is not an osu!mania map")
+	}
+
+	keyCount := m.KeyCount()
+	windows := NewManiaHitWindows(m.OverallDifficulty)
+
+	// split the notes and key presses up by column, since each column is
+	// judged independently
+	columns := m.ManiaColumns()
+	events := make([][]keyEvent, keyCount)
+	prevKeys := 0
+	for _, frame := range frames {
+		for c := 0; c < keyCount; c++ {
+			was, is := prevKeys&(1<<c) != 0, frame.Keys&(1<<c) != 0
+			if was != is {
+				events[c] = append(events[c], keyEvent{float64(frame.Time), is})
+			}
+		}
+		prevKeys = frame.Keys
+	}
+
+	result := &ManiaResult{}
+	for c := 0; c < keyCount; c++ {
+		judgeColumn(c, columns[c], events[c], windows, result)
+	}
+
+	// combo depends on the order things happened in, across all columns
+	sort.SliceStable(result.Results, func(i, j int) bool {
+		return result.Results[i].Time < result.Results[j].Time
+	})
+
+	var points float64
+	for _, r := range result.Results {
+		result.Counts[r.Judgement]++
+		if r.Judgement == MANIA_MISS {
+			result.Combo = 0
+		} else {
+			result.Combo++
+			if result.Combo > result.MaxCombo {
+				result.MaxCombo = result.Combo
+			}
+		}
+		points += maniaJudgementPoints(r.Judgement)
+	}
+
+	result.Accuracy = 1
+	if len(result.Results) > 0 {
+		result.Accuracy = points / (300 * float64(len(result.Results)))
+	}
+	return result, nil
+}
+
+func judgeColumn(column int, notes []ManiaNote, events []keyEvent, windows ManiaHitWindows, result *ManiaResult) {
+	releaseWindows := ManiaHitWindows{
+		Max:   windows.Max * MANIA_RELEASE_LENIENCE,
+		Great: windows.Great * MANIA_RELEASE_LENIENCE,
+		Good:  windows.Good * MANIA_RELEASE_LENIENCE,
+		Ok:    windows.Ok * MANIA_RELEASE_LENIENCE,
+		Meh:   windows.Meh * MANIA_RELEASE_LENIENCE,
+		Miss:  windows.Miss * MANIA_RELEASE_LENIENCE,
+	}
+
+	add := func(note *ManiaNote, tail bool, judgement ManiaJudgement, time, offset float64) {
+		result.Results = append(result.Results, ManiaNoteResult{note.ObjectIndex, column, tail, judgement, time, offset})
+	}
+
+	next := 0
+	var holding *ManiaNote
+
+	// expire judges everything that can no longer be hit by time t
+	expire := func(t float64) {
+		if holding != nil && t > holding.EndTime+releaseWindows.Meh {
+			add(holding, true, MANIA_50, holding.EndTime+releaseWindows.Meh, releaseWindows.Meh)
+			holding = nil
+		}
+		for next < len(notes) && t > notes[next].StartTime+windows.Meh {
+			note := &notes[next]
+			add(note, false, MANIA_MISS, note.StartTime+windows.Meh, 0)
+			if note.Hold {
+				add(note, true, MANIA_MISS, note.EndTime, 0)
+			}
+			next++
+		}
+	}
+
+	for _, ev := range events {
+		expire(ev.time)
+
+		if !ev.pressed {
+			if holding != nil {
+				offset := ev.time - holding.EndTime
+				judgement := MANIA_MISS
+				if offset >= -releaseWindows.Meh {
+					judgement = releaseWindows.JudgementFor(offset)
+				}
+				add(holding, true, judgement, ev.time, offset)
+				holding = nil
+			}
+			continue
+		}
+
+		if next >= len(notes) || ev.time < notes[next].StartTime-windows.Miss {
+			result.GhostTaps++
+			continue
+		}
+
+		note := &notes[next]
+		next++
+
+		offset := ev.time - note.StartTime
+		judgement := windows.JudgementFor(offset)
+		add(note, false, judgement, ev.time, offset)
+
+		if note.Hold {
+			if judgement == MANIA_MISS {
+				add(note, true, MANIA_MISS, note.EndTime, 0)
+			} else {
+				holding = note
+			}
+		}
+	}
+
+	expire(math.Inf(1))
+}
+
+func maniaJudgementPoints(judgement ManiaJudgement) float64 {
+	switch judgement {
+	case MANIA_MAX, MANIA_300:
+		return 300
+	case MANIA_200:
+		return 200
+	case MANIA_100:
+		return 100
+	case MANIA_50:
+		return 50
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+package osu
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSimulateMania(t *testing.T) {
+	m, err := ParseBeatmap(strings.NewReader(maniaMap))
+	if err != nil {
+		t.Fatalf("failed to parse mania map: %v", err)
+	}
+	m.OverallDifficulty = 8
+
+	frames := []ReplayFrame{
+		{Time: 800, Keys: 0b0010}, // ghost tap in column 1
+		{Time: 850, Keys: 0},
+		{Time: 1005, Keys: 0b0011}, // column 0 MAX, hold head MAX
+		{Time: 1020, Keys: 0b0010},
+		{Time: 1300, Keys: 0},      // released 200ms early: tail miss
+		{Time: 2030, Keys: 0b1000}, // hold head 300 (30ms late)
+		{Time: 2800, Keys: 0},      // tail released 50ms late
+	}
+
+	result, err := SimulateMania(m, frames)
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+
+	expected := []ManiaJudgement{MANIA_MAX, MANIA_MAX, MANIA_MISS, MANIA_300, MANIA_300}
+	if len(result.Results) != len(expected) {
+		t.Fatalf("expected %d judgements, got %+v", len(expected), result.Results)
+	}
+	for i, r := range result.Results {
+		if r.Judgement != expected[i] {
+			t.Errorf("judgement %d: expected %d, got %+v", i, expected[i], r)
+		}
+	}
+	if result.GhostTaps != 1 {
+		t.Errorf("expected 1 ghost tap, got %d", result.GhostTaps)
+	}
+	if result.MaxCombo != 2 || result.Combo != 2 {
+		t.Errorf("wrong combo: max %d, final %d", result.MaxCombo, result.Combo)
+	}
+	if result.Accuracy != 0.8 {
+		t.Errorf("expected 80%% accuracy, got %v", result.Accuracy)
+	}
+
+	// not pressing anything misses every head and tail
+	result, _ = SimulateMania(m, nil)
+	if result.Counts[MANIA_MISS] != 5 || result.Accuracy != 0 {
+		t.Errorf("expected all misses, got %+v", result.Counts)
+	}
+
+	// holding past the end of the release window gets a 50
+	result, _ = SimulateMania(m, []ReplayFrame{{Time: 2000, Keys: 0b1000}, {Time: 5000, Keys: 0}})
+	last := result.Results[len(result.Results)-1]
+	if !last.Tail || last.Judgement != MANIA_50 {
+		t.Errorf("expected held tail to get a 50, got %+v", last)
+	}
+}
+
+func TestManiaColumns(t *testing.T) {
+	m, err := ParseBeatmap(strings.NewReader(maniaMap))
+	if err != nil {
+		t.Fatalf("failed to parse mania map: %v", err)
+	}
+
+	notes := m.ManiaNotes()
+	expected := []ManiaNote{
+		{ObjectIndex: 0, Column: 0, StartTime: 1000, EndTime: 1000},
+		{ObjectIndex: 1, Column: 1, StartTime: 1000, EndTime: 1500, Hold: true},
+		{ObjectIndex: 2, Column: 3, StartTime: 2000, EndTime: 2750, Hold: true},
+	}
+	if len(notes) != len(expected) {
+		t.Fatalf("expected %d notes, got %+v", len(expected), notes)
+	}
+	for i := range notes {
+		if notes[i] != expected[i] {
+			t.Errorf("note %d: expected %+v, got %+v", i, expected[i], notes[i])
+		}
+	}
+
+	columns := m.ManiaColumns()
+	if len(columns) != 4 || len(columns[0]) != 1 || len(columns[2]) != 0 || columns[3][0].ObjectIndex != 2 {
+		t.Errorf("wrong columns: %+v", columns)
+	}
+
+	tests := []struct {
+		circleSize float64
+		keyCount   int
+		stages     int
+	}{
+		{0, 1, 1},
+		{7, 7, 1},
+		{10, 10, 1},
+		{11, 10, 2},
+		{14, 14, 2},
+		{18, 18, 2},
+	}
+	for _, test := range tests {
+		m.CircleSize = test.circleSize
+		if keys, stages := m.KeyCount(), m.ManiaStages(); keys != test.keyCount || len(stages) != test.stages {
+			t.Errorf("CS %v: expected %d keys in %d stages, got %d in %v", test.circleSize, test.keyCount, test.stages, keys, stages)
+		}
+	}
+
+	// the second stage of a 14 key map starts at column 7
+	m.CircleSize = 14
+	if stage, column := m.ManiaStageColumn(9); stage != 1 || column != 2 {
+		t.Errorf("expected column 2 of stage 1, got column %d of stage %d", column, stage)
+	}
+	if stage, column := m.ManiaStageColumn(6); stage != 0 || column != 6 {
+		t.Errorf("expected column 6 of stage 0, got column %d of stage %d", column, stage)
+	}
+}
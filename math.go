@@ -1 +1,14 @@
 package osu
+
+// DifficultyRange maps a difficulty setting from 0-10 onto a range of values,
+// with 5 giving mid. This is how the game turns settings like approach rate
+// into actual timings.
+func DifficultyRange(difficulty, min, mid, max float64) float64 {
+	if difficulty > 5 {
+		return mid + (max-mid)*(difficulty-5)/5
+	}
+	if difficulty < 5 {
+		return mid - (mid-min)*(5-difficulty)/5
+	}
+	return mid
+}
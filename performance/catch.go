@@ -0,0 +1,67 @@
+package performance
+
+import (
+	"math"
+
+	"github.com/iptq/osu-go/difficulty"
+)
+
+// CatchPerformance is the performance of an osu!catch score.
+type CatchPerformance struct {
+	Total float64
+}
+
+// CalculateCatch works out how much an osu!catch score is worth. Count300 is
+// the number of fruits caught, Count100 the droplets, Count50 the tiny
+// droplets and CountKatu the tiny droplets that were missed. CountMiss only
+// counts fruits and droplets.
+func CalculateCatch(attrs difficulty.CatchAttributes, score Score) CatchPerformance {
+	value := math.Pow(5*math.Max(1, attrs.StarRating/0.0049)-4, 2) / 100000
+
+	// only objects that give combo count towards the length
+	comboHits := float64(score.Count300 + score.Count100 + score.CountMiss)
+	lengthBonus := 0.95 + 0.3*math.Min(1, comboHits/2500)
+	if comboHits > 2500 {
+		lengthBonus += math.Log10(comboHits/2500) * 0.475
+	}
+	value *= lengthBonus
+
+	value *= math.Pow(0.97, float64(score.CountMiss))
+	value *= comboScaling(score.MaxCombo, attrs.MaxCombo)
+
+	ar := attrs.ApproachRate
+	approachRateFactor := 1.0
+	if ar > 9 {
+		approachRateFactor += 0.1 * (ar - 9)
+	}
+	if ar > 10 {
+		approachRateFactor += 0.1 * (ar - 10)
+	} else if ar < 8 {
+		approachRateFactor += 0.025 * (8 - ar)
+	}
+	value *= approachRateFactor
+
+	// Hidden is worth almost nothing at the highest approach rates
+	if score.Mods&difficulty.MOD_HIDDEN != 0 {
+		if ar <= 10 {
+			value *= 1.05 + 0.075*(10-ar)
+		} else {
+			value *= 1.01 + 0.04*(11-math.Min(11, ar))
+		}
+	}
+	if score.Mods&difficulty.MOD_FLASHLIGHT != 0 {
+		value *= 1.35 * lengthBonus
+	}
+
+	totalHits := float64(score.Count300 + score.Count100 + score.Count50 + score.CountMiss + score.CountKatu)
+	accuracy := 0.0
+	if totalHits > 0 {
+		accuracy = clamp(float64(score.Count300+score.Count100+score.Count50)/totalHits, 0, 1)
+	}
+	value *= math.Pow(accuracy, 5.5)
+
+	if score.Mods&difficulty.MOD_NOFAIL != 0 {
+		value *= 0.9
+	}
+	return CatchPerformance{Total: value}
+}
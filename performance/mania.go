@@ -0,0 +1,67 @@
+package performance
+
+import (
+	"math"
+
+	"github.com/iptq/osu-go/difficulty"
+)
+
+// ManiaPerformance is the performance of an osu!mania score, along with the
+// parts it's made up of.
+type ManiaPerformance struct {
+	Total      float64
+	Difficulty float64
+	Accuracy   float64
+}
+
+// CalculateMania works out how much an osu!mania score is worth, which mostly
+// depends on TotalScore rather than the hit counts.
+func CalculateMania(attrs difficulty.ManiaAttributes, score Score) ManiaPerformance {
+	totalHits := float64(score.CountGeki + score.Count300 + score.CountKatu + score.Count100 + score.Count50 + score.CountMiss)
+
+	// scores are scaled up to what they would be without mods that lower the
+	// score multiplier
+	scoreMultiplier := 1.0
+	for _, mod := range []difficulty.Mods{difficulty.MOD_NOFAIL, difficulty.MOD_EASY, difficulty.MOD_HALFTIME} {
+		if score.Mods&mod != 0 {
+			scoreMultiplier *= 0.5
+		}
+	}
+	scaledScore := float64(score.TotalScore) / scoreMultiplier
+
+	multiplier := 0.8
+	if score.Mods&difficulty.MOD_NOFAIL != 0 {
+		multiplier *= 0.9
+	}
+	if score.Mods&difficulty.MOD_EASY != 0 {
+		multiplier *= 0.5
+	}
+
+	difficultyValue := math.Pow(5*math.Max(1, attrs.StarRating/0.2)-4, 2.2) / 135
+	difficultyValue *= 1 + 0.1*math.Min(1, totalHits/1500)
+	switch {
+	case scaledScore <= 500000:
+		difficultyValue = 0
+	case scaledScore <= 600000:
+		difficultyValue *= (scaledScore - 500000) / 100000 * 0.3
+	case scaledScore <= 700000:
+		difficultyValue *= 0.3 + (scaledScore-600000)/100000*0.25
+	case scaledScore <= 800000:
+		difficultyValue *= 0.55 + (scaledScore-700000)/100000*0.2
+	case scaledScore <= 900000:
+		difficultyValue *= 0.75 + (scaledScore-800000)/100000*0.15
+	default:
+		difficultyValue *= 0.9 + (scaledScore-900000)/100000*0.1
+	}
+
+	accuracyValue := 0.0
+	if attrs.GreatHitWindow > 0 {
+		accuracyValue = math.Max(0, 0.2-(attrs.GreatHitWindow-34)*0.006667) * difficultyValue * math.Pow(math.Max(0, scaledScore-960000)/40000, 1.1)
+	}
+
+	return ManiaPerformance{
+		Total:      combine(difficultyValue, accuracyValue) * multiplier,
+		Difficulty: difficultyValue,
+		Accuracy:   accuracyValue,
+	}
+}
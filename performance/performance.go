@@ -0,0 +1,80 @@
+// Package performance calculates how many performance points (pp) a score is
+// worth, from the difficulty attributes of the map it was set on.
+package performance
+
+import (
+	"errors"
+	"math"
+
+	osu "github.com/iptq/osu-go"
+	"github.com/iptq/osu-go/difficulty"
+)
+
+// Score describes a play, with the same hit counts as a replay or score
+// submission.
+type Score struct {
+	// Mode is the mode the score was set in, which can be different from the
+	// map's if it was converted
+	Mode osu.Mode
+	Mods difficulty.Mods
+
+	Count300  int
+	Count100  int
+	Count50   int
+	CountMiss int
+
+	// CountGeki and CountKatu are only used in some modes. In osu!mania
+	// they're the MAX and 200 counts, and in osu!catch CountKatu is the
+	// number of tiny droplets that were missed.
+	CountGeki int
+	CountKatu int
+
+	MaxCombo int
+
+	// TotalScore is only used for osu!mania
+	TotalScore int
+}
+
+// Calculate works out the difficulty of the map for the score's mode and mods,
+// and then how much the score is worth. To avoid recalculating the
+// difficulty for every score, use the calculator for the mode instead.
+func Calculate(m *osu.Beatmap, score Score) (float64, error) {
+	switch score.Mode {
+	case osu.MODE_STD:
+		return CalculateStandard(difficulty.CalculateStandard(m, score.Mods), score).Total, nil
+	case osu.MODE_TAIKO:
+		return CalculateTaiko(difficulty.CalculateTaiko(m, score.Mods), score).Total, nil
+	case osu.MODE_CTB:
+		return CalculateCatch(difficulty.CalculateCatch(m, score.Mods), score).Total, nil
+	case osu.MODE_MANIA:
+		attrs, err := difficulty.CalculateMania(m, score.Mods)
+		if err != nil {
+			return 0, err
+		}
+		return CalculateMania(attrs, score).Total, nil
+	}
+	return 0, errors.New("unknown mode")
+}
+
+// combine adds up parts of a performance value so that the biggest ones
+// count for the most.
+func combine(values ...float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += math.Pow(v, 1.1)
+	}
+	return math.Pow(total, 1/1.1)
+}
+
+// comboScaling scales a value down for scores that didn't get the full
+// combo.
+func comboScaling(scoreMaxCombo, maxCombo int) float64 {
+	if maxCombo <= 0 {
+		return 1
+	}
+	return math.Min(math.Pow(float64(scoreMaxCombo), 0.8)/math.Pow(float64(maxCombo), 0.8), 1)
+}
+
+func clamp(x, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, x))
+}
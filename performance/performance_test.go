@@ -0,0 +1,196 @@
+package performance
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"testing"
+
+	osu "github.com/iptq/osu-go"
+	"github.com/iptq/osu-go/difficulty"
+)
+
+func loadMap(t *testing.T, name string) *osu.Beatmap {
+	f, err := os.Open("../test/" + name)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", name, err)
+	}
+	defer f.Close()
+
+	m, err := osu.ParseBeatmap(f)
+	if err != nil {
+		t.Fatalf("failed to parse %s: %v", name, err)
+	}
+	return m
+}
+
+// expectClose checks a value against a known one, allowing for a relative
+// difference of tolerance. The pp the tests expect weren't produced by this
+// package: they were worked out separately from the game's formulas for each
+// mode, from the difficulty attributes in the test.
+func expectClose(t *testing.T, name string, got, expected, tolerance float64) {
+	t.Helper()
+	if math.Abs(got-expected) > tolerance*math.Abs(expected) {
+		t.Errorf("expected %s to be %v, got %v", name, expected, got)
+	}
+}
+
+func TestStandardPerformance(t *testing.T) {
+	m := loadMap(t, "Tanaka Aimi - KakushintekiMetamorphose! (deadcode) [IT'S SHOWTIME!!!!!].osu")
+	attrs := difficulty.CalculateStandard(m, 0)
+	objects := attrs.CircleCount + attrs.SliderCount + attrs.SpinnerCount
+
+	ss := CalculateStandard(attrs, Score{Count300: objects, MaxCombo: attrs.MaxCombo})
+	if ss.Total < 500 || ss.Total > 800 {
+		t.Errorf("expected around 650pp, got %+v", ss)
+	}
+	if ss.Aim <= ss.Speed || ss.Accuracy <= 0 || ss.EffectiveMissCount != 0 {
+		t.Errorf("wrong performance: %+v", ss)
+	}
+
+	// the combo was broken three times without any misses
+	broken := CalculateStandard(attrs, Score{Count300: objects - 10, Count100: 10, MaxCombo: attrs.MaxCombo / 4})
+	if broken.Total >= ss.Total || broken.EffectiveMissCount <= 0 {
+		t.Errorf("expected a broken combo to be worth less, got %+v", broken)
+	}
+
+	mods := difficulty.MOD_HIDDEN | difficulty.MOD_HARDROCK
+	hdhr := CalculateStandard(difficulty.CalculateStandard(m, mods), Score{Mods: mods, Count300: objects, MaxCombo: attrs.MaxCombo})
+	if hdhr.Total <= ss.Total {
+		t.Errorf("expected HDHR to be worth more, got %+v", hdhr)
+	}
+
+	fl := CalculateStandard(difficulty.CalculateStandard(m, difficulty.MOD_FLASHLIGHT), Score{Mods: difficulty.MOD_FLASHLIGHT, Count300: objects, MaxCombo: attrs.MaxCombo})
+	if ss.Flashlight != 0 || fl.Flashlight <= 0 || fl.Total <= ss.Total {
+		t.Errorf("expected Flashlight to be worth more, got %+v", fl)
+	}
+
+	relax := CalculateStandard(difficulty.CalculateStandard(m, difficulty.MOD_RELAX), Score{Mods: difficulty.MOD_RELAX, Count300: objects, MaxCombo: attrs.MaxCombo})
+	if relax.Speed != 0 || relax.Accuracy != 0 || relax.Total >= ss.Total {
+		t.Errorf("expected relax to only count aim, got %+v", relax)
+	}
+
+	attrs = difficulty.StandardAttributes{
+		AimDifficulty:        3,
+		SpeedDifficulty:      2.5,
+		FlashlightDifficulty: 2,
+		SpeedNoteCount:       300,
+		SliderFactor:         0.98,
+		ApproachRate:         9.3,
+		OverallDifficulty:    8.5,
+		MaxCombo:             1200,
+		CircleCount:          500,
+		SliderCount:          250,
+		SpinnerCount:         2,
+	}
+	for _, test := range []struct {
+		name     string
+		score    Score
+		expected float64
+	}{
+		{"SS", Score{Count300: 752, MaxCombo: 1200}, 274.40931},
+		{"HD SS", Score{Mods: difficulty.MOD_HIDDEN, Count300: 752, MaxCombo: 1200}, 301.71344},
+		{"FL SS", Score{Mods: difficulty.MOD_FLASHLIGHT, Count300: 752, MaxCombo: 1200}, 370.40180},
+		{"misses", Score{Count300: 740, Count100: 8, Count50: 2, CountMiss: 2, MaxCombo: 900}, 192.47575},
+		{"relax", Score{Mods: difficulty.MOD_RELAX, Count300: 740, Count100: 8, Count50: 2, CountMiss: 2, MaxCombo: 900}, 76.718337},
+		{"HDHR broken combo", Score{Mods: difficulty.MOD_HIDDEN | difficulty.MOD_HARDROCK, Count300: 730, Count100: 20, Count50: 2, MaxCombo: 1150}, 229.01984},
+	} {
+		expectClose(t, test.name+" pp", CalculateStandard(attrs, test.score).Total, test.expected, 1e-6)
+	}
+}
+
+func TestTaikoPerformance(t *testing.T) {
+	attrs := difficulty.CalculateTaiko(loadMap(t, "Minami Kuribayashi - ZERO!! (Short Size) (qoot8123) [Oni].osu"), 0)
+
+	ss := CalculateTaiko(attrs, Score{Count300: attrs.MaxCombo, MaxCombo: attrs.MaxCombo})
+	if ss.Total <= 0 || ss.Difficulty <= 0 || ss.Accuracy <= 0 {
+		t.Errorf("wrong performance: %+v", ss)
+	}
+
+	worse := CalculateTaiko(attrs, Score{Count300: attrs.MaxCombo - 20, Count100: 15, CountMiss: 5, MaxCombo: 100})
+	if worse.Total >= ss.Total {
+		t.Errorf("expected %.2fpp to be less than %.2fpp", worse.Total, ss.Total)
+	}
+
+	attrs = difficulty.TaikoAttributes{StarRating: 4, GreatHitWindow: 35, MaxCombo: 800}
+	expectClose(t, "SS pp", CalculateTaiko(attrs, Score{Count300: 800, MaxCombo: 800}).Total, 170.48393, 1e-6)
+	hidden := CalculateTaiko(attrs, Score{Mods: difficulty.MOD_HIDDEN, Count300: 770, Count100: 25, CountMiss: 5, MaxCombo: 400})
+	expectClose(t, "HD pp", hidden.Total, 152.51473, 1e-6)
+}
+
+func TestCatchPerformance(t *testing.T) {
+	m := loadMap(t, "David Wise - Gang-Plank Galleon (Hara) [Larry Kong].osu")
+	attrs := difficulty.CalculateCatch(m, 0)
+
+	ss := CalculateCatch(attrs, Score{Count300: attrs.MaxCombo, MaxCombo: attrs.MaxCombo})
+	if ss.Total <= 0 {
+		t.Errorf("wrong performance: %+v", ss)
+	}
+
+	// missing tiny droplets only costs accuracy
+	droplets := CalculateCatch(attrs, Score{Count300: attrs.MaxCombo, CountKatu: 20, MaxCombo: attrs.MaxCombo})
+	if droplets.Total >= ss.Total {
+		t.Errorf("expected %.2fpp to be less than %.2fpp", droplets.Total, ss.Total)
+	}
+
+	hidden := CalculateCatch(attrs, Score{Mods: difficulty.MOD_HIDDEN, Count300: attrs.MaxCombo, MaxCombo: attrs.MaxCombo})
+	if hidden.Total <= ss.Total {
+		t.Errorf("expected Hidden to be worth more, got %.2fpp", hidden.Total)
+	}
+
+	attrs = difficulty.CatchAttributes{StarRating: 4, ApproachRate: 9, MaxCombo: 900}
+	expectClose(t, "SS pp", CalculateCatch(attrs, Score{Count300: 900, MaxCombo: 900}).Total, 175.91459, 1e-6)
+	hidden = CalculateCatch(attrs, Score{Mods: difficulty.MOD_HIDDEN, Count300: 880, Count100: 15, Count50: 200, CountKatu: 30, CountMiss: 5, MaxCombo: 600})
+	expectClose(t, "HD pp", hidden.Total, 103.34424, 1e-6)
+}
+
+func TestManiaPerformance(t *testing.T) {
+	attrs := difficulty.ManiaAttributes{StarRating: 4, GreatHitWindow: 40, NoteCount: 1000, MaxCombo: 1000}
+
+	expected := map[int]float64{650000: 61.683724, 950000: 137.88127, 985000: 152.73154}
+	for totalScore, pp := range expected {
+		expectClose(t, fmt.Sprintf("pp for a score of %d", totalScore), CalculateMania(attrs, Score{Count300: 1000, MaxCombo: 1000, TotalScore: totalScore}).Total, pp, 1e-6)
+	}
+
+	last := 0.0
+	for _, totalScore := range []int{550000, 750000, 950000, 1000000} {
+		p := CalculateMania(attrs, Score{Count300: 1000, MaxCombo: 1000, TotalScore: totalScore})
+		if p.Total <= last {
+			t.Errorf("score %d: expected more than %.2fpp, got %+v", totalScore, last, p)
+		}
+		last = p.Total
+	}
+
+	if p := CalculateMania(attrs, Score{Count300: 1000, TotalScore: 500000}); p.Total != 0 {
+		t.Errorf("expected nothing for a low score, got %+v", p)
+	}
+
+	// No Fail halves the score, which is scaled back up
+	nf := CalculateMania(attrs, Score{Mods: difficulty.MOD_NOFAIL, Count300: 1000, TotalScore: 475000})
+	if nomod := CalculateMania(attrs, Score{Count300: 1000, TotalScore: 950000}); nf.Difficulty != nomod.Difficulty || nf.Total >= nomod.Total {
+		t.Errorf("expected %+v to be worth a bit less than %+v", nf, nomod)
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	m := loadMap(t, "David Wise - Gang-Plank Galleon (Hara) [Larry Kong].osu")
+	attrs := difficulty.CalculateCatch(m, 0)
+	score := Score{Mode: osu.MODE_CTB, Count300: attrs.MaxCombo, MaxCombo: attrs.MaxCombo}
+
+	pp, err := Calculate(m, score)
+	if err != nil {
+		t.Fatalf("failed to calculate performance: %v", err)
+	}
+	if expected := CalculateCatch(attrs, score).Total; pp != expected {
+		t.Errorf("expected %.2fpp, got %.2fpp", expected, pp)
+	}
+
+	score.Mode = osu.MODE_MANIA
+	if _, err := Calculate(m, score); err == nil {
+		t.Error("expected an error for converting to osu!mania")
+	}
+	score.Mode = 4
+	if _, err := Calculate(m, score); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
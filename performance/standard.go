@@ -0,0 +1,241 @@
+package performance
+
+import (
+	"math"
+
+	"github.com/iptq/osu-go/difficulty"
+)
+
+// StandardPerformance is the performance of an osu!standard score, along with
+// the parts it's made up of.
+type StandardPerformance struct {
+	Total      float64
+	Aim        float64
+	Speed      float64
+	Accuracy   float64
+	Flashlight float64
+
+	// EffectiveMissCount is the number of misses, plus a guess at how many
+	// slider breaks there were
+	EffectiveMissCount float64
+}
+
+// CalculateStandard works out how much an osu!standard score is worth.
+func CalculateStandard(attrs difficulty.StandardAttributes, score Score) StandardPerformance {
+	s := standardScore{Score: score, attrs: attrs}
+	s.totalHits = float64(score.Count300 + score.Count100 + score.Count50 + score.CountMiss)
+	if s.totalHits > 0 {
+		s.accuracy = float64(300*score.Count300+100*score.Count100+50*score.Count50) / (300 * s.totalHits)
+	}
+	s.effectiveMissCount = s.calculateEffectiveMissCount()
+
+	multiplier := difficulty.STANDARD_PERFORMANCE_BASE_MULTIPLIER
+	if score.Mods&difficulty.MOD_NOFAIL != 0 {
+		multiplier *= math.Max(0.9, 1-0.02*s.effectiveMissCount)
+	}
+	if score.Mods&difficulty.MOD_SPUNOUT != 0 && s.totalHits > 0 {
+		multiplier *= 1 - math.Pow(float64(attrs.SpinnerCount)/s.totalHits, 0.85)
+	}
+	if score.Mods&difficulty.MOD_RELAX != 0 {
+		// 100s and 50s are treated as misses, since they're probably from
+		// not being able to tap, and OD 13.33 is where the 300 window
+		// disappears
+		okMultiplier, mehMultiplier := 1.0, 1.0
+		if attrs.OverallDifficulty > 0 {
+			okMultiplier = math.Max(0, 1-math.Pow(attrs.OverallDifficulty/13.33, 1.8))
+			mehMultiplier = math.Max(0, 1-math.Pow(attrs.OverallDifficulty/13.33, 5))
+		}
+		s.effectiveMissCount = math.Min(s.effectiveMissCount+float64(score.Count100)*okMultiplier+float64(score.Count50)*mehMultiplier, s.totalHits)
+	}
+
+	p := StandardPerformance{
+		Aim:                s.aimValue(),
+		Speed:              s.speedValue(),
+		Accuracy:           s.accuracyValue(),
+		Flashlight:         s.flashlightValue(),
+		EffectiveMissCount: s.effectiveMissCount,
+	}
+	p.Total = combine(p.Aim, p.Speed, p.Accuracy, p.Flashlight) * multiplier
+	return p
+}
+
+type standardScore struct {
+	Score
+	attrs difficulty.StandardAttributes
+
+	totalHits          float64
+	accuracy           float64
+	effectiveMissCount float64
+}
+
+// calculateEffectiveMissCount guesses how many times the combo was broken,
+// including on slider ends, which don't show up as misses.
+func (s *standardScore) calculateEffectiveMissCount() float64 {
+	comboBasedMissCount := 0.0
+	if s.attrs.SliderCount > 0 {
+		fullComboThreshold := float64(s.attrs.MaxCombo) - 0.1*float64(s.attrs.SliderCount)
+		if float64(s.MaxCombo) < fullComboThreshold {
+			comboBasedMissCount = fullComboThreshold / math.Max(1, float64(s.MaxCombo))
+		}
+	}
+
+	// there can't be more breaks than there were objects that weren't 300s
+	comboBasedMissCount = math.Min(comboBasedMissCount, float64(s.Count100+s.Count50+s.CountMiss))
+	return math.Max(float64(s.CountMiss), comboBasedMissCount)
+}
+
+func (s *standardScore) lengthBonus() float64 {
+	bonus := 0.95 + 0.4*math.Min(1, s.totalHits/2000)
+	if s.totalHits > 2000 {
+		bonus += math.Log10(s.totalHits/2000) * 0.5
+	}
+	return bonus
+}
+
+func (s *standardScore) aimValue() float64 {
+	rawAim := s.attrs.AimDifficulty
+	if s.Mods&difficulty.MOD_TOUCHDEVICE != 0 {
+		rawAim = math.Pow(rawAim, 0.8)
+	}
+
+	aimValue := difficulty.StandardStrainToPerformance(rawAim)
+	lengthBonus := s.lengthBonus()
+	aimValue *= lengthBonus
+
+	// every miss counts for less the more objects there are
+	if s.effectiveMissCount > 0 {
+		aimValue *= 0.97 * math.Pow(1-math.Pow(s.effectiveMissCount/s.totalHits, 0.775), s.effectiveMissCount)
+	}
+	aimValue *= comboScaling(s.MaxCombo, s.attrs.MaxCombo)
+
+	approachRateFactor := 0.0
+	if s.attrs.ApproachRate > 10.33 {
+		approachRateFactor = 0.3 * (s.attrs.ApproachRate - 10.33)
+	} else if s.attrs.ApproachRate < 8 {
+		approachRateFactor = 0.05 * (8 - s.attrs.ApproachRate)
+	}
+	if s.Mods&difficulty.MOD_RELAX != 0 {
+		approachRateFactor = 0
+	}
+	aimValue *= 1 + approachRateFactor*lengthBonus
+
+	// Hidden is worth more at lower approach rates
+	if s.Mods&difficulty.MOD_HIDDEN != 0 {
+		aimValue *= 1 + 0.04*(12-s.attrs.ApproachRate)
+	}
+
+	// assume 15% of the sliders are hard, and that any slider ends that were
+	// dropped were on those
+	estimateDifficultSliders := float64(s.attrs.SliderCount) * 0.15
+	if s.attrs.SliderCount > 0 {
+		maybeDropped := math.Min(float64(s.Count100+s.Count50+s.CountMiss), float64(s.attrs.MaxCombo-s.MaxCombo))
+		estimateSliderEndsDropped := math.Max(0, math.Min(estimateDifficultSliders, maybeDropped))
+		sliderNerfFactor := (1-s.attrs.SliderFactor)*math.Pow(1-estimateSliderEndsDropped/estimateDifficultSliders, 3) + s.attrs.SliderFactor
+		aimValue *= sliderNerfFactor
+	}
+
+	aimValue *= s.accuracy
+	aimValue *= 0.98 + math.Pow(s.attrs.OverallDifficulty, 2)/2500
+	return aimValue
+}
+
+func (s *standardScore) speedValue() float64 {
+	if s.Mods&difficulty.MOD_RELAX != 0 {
+		return 0
+	}
+
+	speedValue := difficulty.StandardStrainToPerformance(s.attrs.SpeedDifficulty)
+	lengthBonus := s.lengthBonus()
+	speedValue *= lengthBonus
+
+	if s.effectiveMissCount > 0 {
+		speedValue *= 0.97 * math.Pow(1-math.Pow(s.effectiveMissCount/s.totalHits, 0.775), math.Pow(s.effectiveMissCount, 0.875))
+	}
+	speedValue *= comboScaling(s.MaxCombo, s.attrs.MaxCombo)
+
+	approachRateFactor := 0.0
+	if s.attrs.ApproachRate > 10.33 {
+		approachRateFactor = 0.3 * (s.attrs.ApproachRate - 10.33)
+	}
+	speedValue *= 1 + approachRateFactor*lengthBonus
+
+	if s.Mods&difficulty.MOD_HIDDEN != 0 {
+		speedValue *= 1 + 0.04*(12-s.attrs.ApproachRate)
+	}
+
+	// work out the accuracy on the notes that are hard to tap, assuming the
+	// worst, which is that every 100 and 50 was on one of them
+	relevantTotalDiff := s.totalHits - s.attrs.SpeedNoteCount
+	relevantCountGreat := math.Max(0, float64(s.Count300)-relevantTotalDiff)
+	relevantCountOk := math.Max(0, float64(s.Count100)-math.Max(0, relevantTotalDiff-float64(s.Count300)))
+	relevantCountMeh := math.Max(0, float64(s.Count50)-math.Max(0, relevantTotalDiff-float64(s.Count300+s.Count100)))
+	relevantAccuracy := 0.0
+	if s.attrs.SpeedNoteCount != 0 {
+		relevantAccuracy = (relevantCountGreat*6 + relevantCountOk*2 + relevantCountMeh) / (s.attrs.SpeedNoteCount * 6)
+	}
+
+	speedValue *= (0.95 + math.Pow(s.attrs.OverallDifficulty, 2)/750) * math.Pow((s.accuracy+relevantAccuracy)/2, (14.5-math.Max(s.attrs.OverallDifficulty, 8))/2)
+
+	// lots of 50s probably means double tapping
+	if count50 := float64(s.Count50); count50 >= s.totalHits/500 {
+		speedValue *= math.Pow(0.99, count50-s.totalHits/500)
+	}
+	return speedValue
+}
+
+// accuracyValue only looks at circles, since sliders and spinners can be hit
+// without good timing.
+func (s *standardScore) accuracyValue() float64 {
+	if s.Mods&difficulty.MOD_RELAX != 0 {
+		return 0
+	}
+
+	circles := float64(s.attrs.CircleCount)
+	betterAccuracyPercentage := 0.0
+	if circles > 0 {
+		betterAccuracyPercentage = ((float64(s.Count300)-(s.totalHits-circles))*6 + float64(s.Count100)*2 + float64(s.Count50)) / (circles * 6)
+	}
+	betterAccuracyPercentage = math.Max(0, betterAccuracyPercentage)
+
+	accuracyValue := math.Pow(1.52163, s.attrs.OverallDifficulty) * math.Pow(betterAccuracyPercentage, 24) * 2.83
+
+	// it's harder to keep accuracy up for longer
+	accuracyValue *= math.Min(1.15, math.Pow(circles/1000, 0.3))
+
+	if s.Mods&difficulty.MOD_HIDDEN != 0 {
+		accuracyValue *= 1.08
+	}
+	if s.Mods&difficulty.MOD_FLASHLIGHT != 0 {
+		accuracyValue *= 1.02
+	}
+	return accuracyValue
+}
+
+func (s *standardScore) flashlightValue() float64 {
+	if s.Mods&difficulty.MOD_FLASHLIGHT == 0 {
+		return 0
+	}
+
+	rawFlashlight := s.attrs.FlashlightDifficulty
+	if s.Mods&difficulty.MOD_TOUCHDEVICE != 0 {
+		rawFlashlight = math.Pow(rawFlashlight, 0.8)
+	}
+	flashlightValue := difficulty.FlashlightStrainToPerformance(rawFlashlight)
+
+	if s.effectiveMissCount > 0 {
+		flashlightValue *= 0.97 * math.Pow(1-math.Pow(s.effectiveMissCount/s.totalHits, 0.775), math.Pow(s.effectiveMissCount, 0.875))
+	}
+	flashlightValue *= comboScaling(s.MaxCombo, s.attrs.MaxCombo)
+
+	// the flashlight gets smaller as the combo goes up, which short maps
+	// don't get to
+	lengthFactor := 0.7 + 0.1*math.Min(1, s.totalHits/200)
+	if s.totalHits > 200 {
+		lengthFactor += 0.2 * math.Min(1, (s.totalHits-200)/200)
+	}
+	flashlightValue *= lengthFactor
+
+	flashlightValue *= 0.5 + s.accuracy/2
+	flashlightValue *= 0.98 + math.Pow(s.attrs.OverallDifficulty, 2)/2500
+	return flashlightValue
+}
@@ -0,0 +1,59 @@
+package performance
+
+import (
+	"math"
+
+	"github.com/iptq/osu-go/difficulty"
+)
+
+// TaikoPerformance is the performance of an osu!taiko score, along with the
+// parts it's made up of.
+type TaikoPerformance struct {
+	Total      float64
+	Difficulty float64
+	Accuracy   float64
+}
+
+// CalculateTaiko works out how much an osu!taiko score is worth. Count100 is
+// the number of goods, and there aren't any 50s.
+func CalculateTaiko(attrs difficulty.TaikoAttributes, score Score) TaikoPerformance {
+	totalHits := float64(score.Count300 + score.Count100 + score.Count50 + score.CountMiss)
+	accuracy := 0.0
+	if totalHits > 0 {
+		accuracy = (float64(score.Count300) + float64(score.Count100)*0.5) / totalHits
+	}
+
+	multiplier := 1.1
+	if score.Mods&difficulty.MOD_NOFAIL != 0 {
+		multiplier *= 0.9
+	}
+	if score.Mods&difficulty.MOD_HIDDEN != 0 {
+		multiplier *= 1.1
+	}
+
+	difficultyValue := math.Pow(5*math.Max(1, attrs.StarRating/0.0075)-4, 2) / 100000
+	lengthBonus := 1 + 0.1*math.Min(1, totalHits/1500)
+	difficultyValue *= lengthBonus
+	difficultyValue *= math.Pow(0.985, float64(score.CountMiss))
+	if score.Mods&difficulty.MOD_HIDDEN != 0 {
+		difficultyValue *= 1.025
+	}
+	if score.Mods&difficulty.MOD_FLASHLIGHT != 0 {
+		difficultyValue *= 1.05 * lengthBonus
+	}
+	difficultyValue *= accuracy
+
+	accuracyValue := 0.0
+	if attrs.GreatHitWindow > 0 {
+		accuracyValue = math.Pow(150/attrs.GreatHitWindow, 1.1) * math.Pow(accuracy, 15) * 22
+
+		// it's harder to keep accuracy up for longer
+		accuracyValue *= math.Min(1.15, math.Pow(totalHits/1500, 0.3))
+	}
+
+	return TaikoPerformance{
+		Total:      combine(difficultyValue, accuracyValue) * multiplier,
+		Difficulty: difficultyValue,
+		Accuracy:   accuracyValue,
+	}
+}
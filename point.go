@@ -6,6 +6,18 @@ type IntPoint struct {
 	x, y int
 }
 
+func NewIntPoint(x, y int) IntPoint {
+	return IntPoint{x, y}
+}
+
+func (p IntPoint) X() int {
+	return p.x
+}
+
+func (p IntPoint) Y() int {
+	return p.y
+}
+
 func (p IntPoint) ToFloat() FloatPoint {
 	return FloatPoint{
 		x: float64(p.x),
@@ -17,6 +29,18 @@ type FloatPoint struct {
 	x, y float64
 }
 
+func NewFloatPoint(x, y float64) FloatPoint {
+	return FloatPoint{x, y}
+}
+
+func (p FloatPoint) X() float64 {
+	return p.x
+}
+
+func (p FloatPoint) Y() float64 {
+	return p.y
+}
+
 func (p FloatPoint) ToInt() IntPoint {
 	return IntPoint{
 		x: int(p.x),
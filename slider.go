@@ -0,0 +1,257 @@
+package osu
+
+import (
+	"math"
+	"sort"
+)
+
+// The path queries below are parameterized by progress along a single pass of
+// the slider, from 0 at the head to 1 at the end of the path, measured by
+// distance traveled rather than by the points making up the path.
+
+// Length returns the length of the path the slider follows, in osu!pixels.
+func (obj ObjSlider) Length() float64 {
+	if len(obj.lengths) == 0 {
+		return 0
+	}
+	return obj.lengths[len(obj.lengths)-1]
+}
+
+// PositionAt returns the position on the path after traveling the given
+// fraction of its length.
+func (obj ObjSlider) PositionAt(progress float64) FloatPoint {
+	if len(obj.spline) == 0 {
+		return IntPoint{obj.x, obj.y}.ToFloat()
+	}
+	if len(obj.spline) == 1 {
+		return obj.spline[0]
+	}
+
+	i, distance := obj.segmentAt(progress)
+	start, end := obj.spline[i-1], obj.spline[i]
+	segmentLength := obj.lengths[i] - obj.lengths[i-1]
+	if segmentLength == 0 {
+		return start
+	}
+
+	t := (distance - obj.lengths[i-1]) / segmentLength
+	return start.Add(end.Sub(start).ScalarMul(t))
+}
+
+// EndPosition returns the position at the end of the path. This is where the
+// slider ends if it has an odd number of slides.
+func (obj ObjSlider) EndPosition() FloatPoint {
+	return obj.PositionAt(1)
+}
+
+// TangentAt returns the unit vector pointing in the direction of travel along
+// the path at the given progress, or the zero vector if the path has no length.
+func (obj ObjSlider) TangentAt(progress float64) FloatPoint {
+	if obj.Length() == 0 {
+		return FloatPoint{}
+	}
+
+	i, _ := obj.segmentAt(progress)
+
+	// look for a segment that actually goes somewhere, in case there are
+	// repeated points
+	for j := i; j < len(obj.spline); j++ {
+		if obj.lengths[j] > obj.lengths[j-1] {
+			return obj.spline[j].Sub(obj.spline[j-1]).Norm()
+		}
+	}
+	for j := i - 1; j > 0; j-- {
+		if obj.lengths[j] > obj.lengths[j-1] {
+			return obj.spline[j].Sub(obj.spline[j-1]).Norm()
+		}
+	}
+	return FloatPoint{}
+}
+
+// segmentAt finds the index of the end of the path segment containing the
+// given progress, along with the distance along the path that it corresponds to.
+func (obj ObjSlider) segmentAt(progress float64) (int, float64) {
+	if progress < 0 {
+		progress = 0
+	} else if progress > 1 {
+		progress = 1
+	}
+
+	distance := progress * obj.Length()
+	i := sort.SearchFloat64s(obj.lengths, distance)
+	if i < 1 {
+		i = 1
+	} else if i >= len(obj.lengths) {
+		i = len(obj.lengths) - 1
+	}
+	return i, distance
+}
+
+const (
+	// the distance a slider travels in one beat at a slider multiplier of 1
+	BASE_SCORING_DISTANCE = 100.0
+
+	// the legacy last tick is this many milliseconds before the end of the slider
+	LEGACY_LAST_TICK_OFFSET = 36.0
+
+	// sliders longer than this are cut short
+	MAX_SLIDER_LENGTH = 100000.0
+)
+
+type SliderEventKind = int
+
+const (
+	SLIDER_HEAD             = 0
+	SLIDER_TICK             = 1
+	SLIDER_REPEAT           = 2
+	SLIDER_LEGACY_LAST_TICK = 3
+	SLIDER_TAIL             = 4
+)
+
+// SliderEvent is something that happens partway through a slider, and has to
+// be judged or hitsounded.
+type SliderEvent struct {
+	Kind SliderEventKind
+	Time float64
+
+	// SpanIndex is which traversal of the path the event belongs to, and
+	// PathProgress is how far along the path it is, from 0 at the head to 1 at
+	// the end
+	SpanIndex    int
+	PathProgress float64
+	Position     FloatPoint
+}
+
+// SliderTiming describes how a slider plays out over time, which depends on
+// the beatmap's settings and the timing point active at the slider's start.
+type SliderTiming struct {
+	// Velocity is in osu!pixels per millisecond
+	Velocity float64
+
+	// TickDistance is the distance along the path between ticks
+	TickDistance float64
+
+	// SpanDuration is the time taken to traverse the path once, and Duration
+	// is the time taken by all of the slides
+	SpanDuration float64
+	Duration     float64
+}
+
+// SliderTiming calculates the timing of the given slider within this beatmap.
+func (m *Beatmap) SliderTiming(obj ObjSlider) SliderTiming {
+	beatLength := 1000.0
+	sliderVelocity := 1.0
+	if tp := m.TimingPointAt(obj.startTime.Milliseconds()); tp != nil {
+		beatLength = tp.GetBeatLength()
+		if inherited, ok := tp.(InheritedTimingPoint); ok {
+			// the game doesn't let slider velocity go outside of these limits
+			sliderVelocity = math.Max(0.1, math.Min(10, inherited.SvMultiplier))
+		}
+	}
+
+	scoringDistance := BASE_SCORING_DISTANCE * m.SliderMultiplier * sliderVelocity
+	velocity := scoringDistance / beatLength
+
+	// before v8, ticks weren't affected by slider velocity
+	tickDistance := scoringDistance / m.SliderTickRate
+	if m.Version < 8 {
+		tickDistance /= sliderVelocity
+	}
+
+	spanDuration := obj.pixelLength / velocity
+	return SliderTiming{
+		Velocity:     velocity,
+		TickDistance: tickDistance,
+		SpanDuration: spanDuration,
+		Duration:     spanDuration * float64(obj.repeatCount),
+	}
+}
+
+// EndTime returns the time at which the slider ends.
+func (obj ObjSlider) EndTime(timing SliderTiming) float64 {
+	return float64(obj.startTime.Milliseconds()) + timing.Duration
+}
+
+// EndTime returns the time at which the given object ends. Sliders need the
+// beatmap to work this out, which is why this isn't part of HitObject.
+func (m *Beatmap) EndTime(obj HitObject) Timestamp {
+	switch o := obj.(type) {
+	case ObjSlider:
+		return TimestampAbsolute(math.Round(o.EndTime(m.SliderTiming(o))))
+	case ObjSpinner:
+		return o.endTime
+	case ObjHoldNote:
+		return o.endTime
+	}
+	return obj.GetStartTime()
+}
+
+// Events returns all of the events that happen during the slider, in order:
+// the head, ticks, repeats, legacy last tick and tail.
+func (obj ObjSlider) Events(timing SliderTiming) (events []SliderEvent) {
+	startTime := float64(obj.startTime.Milliseconds())
+	spanCount := obj.repeatCount
+	spanDuration := timing.SpanDuration
+	length := math.Min(MAX_SLIDER_LENGTH, obj.pixelLength)
+	tickDistance := math.Max(0, math.Min(length, timing.TickDistance))
+
+	// ticks too close to the end of a span are left off
+	minDistanceFromEnd := timing.Velocity * 10
+
+	event := func(kind SliderEventKind, time float64, span int, progress float64) SliderEvent {
+		return SliderEvent{kind, time, span, progress, obj.PositionAt(progress)}
+	}
+
+	events = append(events, event(SLIDER_HEAD, startTime, 0, 0))
+
+	for span := 0; span < spanCount; span++ {
+		spanStartTime := startTime + float64(span)*spanDuration
+		reversed := span%2 == 1
+
+		// ticks are always placed relative to the head, so that they're in the
+		// same place on every span
+		var ticks []SliderEvent
+		for d := tickDistance; tickDistance > 0 && d <= length; d += tickDistance {
+			if d >= length-minDistanceFromEnd {
+				break
+			}
+
+			pathProgress := d / length
+			timeProgress := pathProgress
+			if reversed {
+				timeProgress = 1 - pathProgress
+			}
+			ticks = append(ticks, event(SLIDER_TICK, spanStartTime+timeProgress*spanDuration, span, pathProgress))
+		}
+
+		// going backwards, the ticks are hit in the opposite order
+		if reversed {
+			for i, j := 0, len(ticks)-1; i < j; i, j = i+1, j-1 {
+				ticks[i], ticks[j] = ticks[j], ticks[i]
+			}
+		}
+		events = append(events, ticks...)
+
+		if span < spanCount-1 {
+			events = append(events, event(SLIDER_REPEAT, spanStartTime+spanDuration, span, float64((span+1)%2)))
+		}
+	}
+
+	// the game has always judged the end of a slider slightly early, but no
+	// earlier than halfway through
+	totalDuration := float64(spanCount) * spanDuration
+	finalSpan := spanCount - 1
+	finalSpanStartTime := startTime + float64(finalSpan)*spanDuration
+	finalSpanEndTime := math.Max(startTime+totalDuration/2, finalSpanStartTime+spanDuration-LEGACY_LAST_TICK_OFFSET)
+	finalProgress := 0.0
+	if spanDuration > 0 {
+		finalProgress = (finalSpanEndTime - finalSpanStartTime) / spanDuration
+	}
+	if spanCount%2 == 0 {
+		finalProgress = 1 - finalProgress
+	}
+	events = append(events, event(SLIDER_LEGACY_LAST_TICK, finalSpanEndTime, finalSpan, finalProgress))
+
+	events = append(events, event(SLIDER_TAIL, startTime+totalDuration, finalSpan, float64(spanCount%2)))
+	return
+}
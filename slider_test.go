@@ -0,0 +1,143 @@
+package osu
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSliderPath(t *testing.T) {
+	obj, err := ParseHitObject("0,0,1000,2,0,L|100:0|100:100,1,150")
+	if err != nil {
+		t.Fatalf("failed to parse slider: %v", err)
+	}
+	slider := obj.(ObjSlider)
+
+	if length := slider.Length(); math.Abs(length-150) > 1e-9 {
+		t.Errorf("expected length 150, got %f", length)
+	}
+
+	cases := []struct {
+		progress float64
+		position FloatPoint
+		tangent  FloatPoint
+	}{
+		{0, FloatPoint{0, 0}, FloatPoint{1, 0}},
+		{0.5, FloatPoint{75, 0}, FloatPoint{1, 0}},
+		{0.9, FloatPoint{100, 35}, FloatPoint{0, 1}},
+		{1, FloatPoint{100, 50}, FloatPoint{0, 1}},
+
+		// progress is clamped to the path
+		{2, FloatPoint{100, 50}, FloatPoint{0, 1}},
+	}
+	for _, c := range cases {
+		if p := slider.PositionAt(c.progress); !approxPoint(p, c.position) {
+			t.Errorf("PositionAt(%v): expected %v, got %v", c.progress, c.position, p)
+		}
+		if tangent := slider.TangentAt(c.progress); !approxPoint(tangent, c.tangent) {
+			t.Errorf("TangentAt(%v): expected %v, got %v", c.progress, c.tangent, tangent)
+		}
+	}
+
+	if end := slider.EndPosition(); !approxPoint(end, FloatPoint{100, 50}) {
+		t.Errorf("expected end position (100, 50), got %v", end)
+	}
+}
+
+func TestSliderEdgeHitsounds(t *testing.T) {
+	line := "64,80,730,2,0,B|152:84|192:160,2,225,2|0|8,1:2|0:0|2:3,0:0:0:0:"
+	obj, err := ParseHitObject(line)
+	if err != nil {
+		t.Fatalf("failed to parse slider: %v", err)
+	}
+	slider := obj.(ObjSlider)
+
+	if slider.Slides() != 2 || slider.Repeats() != 1 {
+		t.Errorf("expected 2 slides and 1 repeat, got %d and %d", slider.Slides(), slider.Repeats())
+	}
+	if !reflect.DeepEqual(slider.EdgeHitsounds(), []Hitsound{HITSOUND_WHISTLE, 0, HITSOUND_CLAP}) {
+		t.Errorf("wrong edge hitsounds: %v", slider.EdgeHitsounds())
+	}
+	if !reflect.DeepEqual(slider.EdgeSets(), []EdgeSet{{1, 2}, {0, 0}, {2, 3}}) {
+		t.Errorf("wrong edge sets: %v", slider.EdgeSets())
+	}
+	if out, _ := slider.Serialize(); out != line {
+		t.Errorf("expected '%s', got '%s'", line, out)
+	}
+
+	// older maps leave them off entirely
+	line = "64,80,730,2,4,B|152:84|192:160,2,225"
+	obj, err = ParseHitObject(line)
+	if err != nil {
+		t.Fatalf("failed to parse slider: %v", err)
+	}
+	slider = obj.(ObjSlider)
+	if !reflect.DeepEqual(slider.EdgeHitsounds(), []Hitsound{4, 4, 4}) {
+		t.Errorf("wrong default edge hitsounds: %v", slider.EdgeHitsounds())
+	}
+	if out, _ := slider.Serialize(); out != line {
+		t.Errorf("expected '%s', got '%s'", line, out)
+	}
+
+	// there has to be one for each edge
+	if _, err = ParseHitObject("64,80,730,2,0,B|152:84|192:160,2,225,2|0,1:2|0:0,0:0:0:0:"); err == nil {
+		t.Errorf("expected an error for too few edge hitsounds")
+	}
+}
+
+const sliderTimingMap = `osu file format v14
+
+[Difficulty]
+SliderMultiplier:1.4
+SliderTickRate:1
+
+[TimingPoints]
+0,500,4,2,0,100,1,0
+5000,-50,4,2,0,100,0,0
+
+[HitObjects]
+0,0,1000,2,0,L|280:0,2,280
+0,0,5000,2,0,L|280:0,1,280
+`
+
+func TestSliderTiming(t *testing.T) {
+	m, err := ParseBeatmap(strings.NewReader(sliderTimingMap))
+	if err != nil {
+		t.Fatalf("failed to parse beatmap: %v", err)
+	}
+
+	// 140 pixels per beat at 120 BPM
+	slider := (*m.HitObjects[0]).(ObjSlider)
+	timing := m.SliderTiming(slider)
+	if math.Abs(timing.SpanDuration-1000) > 1e-9 || math.Abs(slider.EndTime(timing)-3000) > 1e-9 {
+		t.Errorf("wrong timing: %+v", timing)
+	}
+
+	expected := []SliderEvent{
+		{SLIDER_HEAD, 1000, 0, 0, FloatPoint{0, 0}},
+		{SLIDER_TICK, 1500, 0, 0.5, FloatPoint{140, 0}},
+		{SLIDER_REPEAT, 2000, 0, 1, FloatPoint{280, 0}},
+		{SLIDER_TICK, 2500, 1, 0.5, FloatPoint{140, 0}},
+		{SLIDER_LEGACY_LAST_TICK, 2964, 1, 0.036, FloatPoint{10.08, 0}},
+		{SLIDER_TAIL, 3000, 1, 0, FloatPoint{0, 0}},
+	}
+	events := slider.Events(timing)
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d: %+v", len(expected), len(events), events)
+	}
+	for i, e := range expected {
+		ev := events[i]
+		if ev.Kind != e.Kind || ev.SpanIndex != e.SpanIndex || math.Abs(ev.Time-e.Time) > 1e-6 ||
+			math.Abs(ev.PathProgress-e.PathProgress) > 1e-6 || !approxPoint(ev.Position, e.Position) {
+			t.Errorf("event %d: expected %+v, got %+v", i, e, ev)
+		}
+	}
+
+	// doubling the slider velocity halves the duration
+	slider = (*m.HitObjects[1]).(ObjSlider)
+	timing = m.SliderTiming(slider)
+	if math.Abs(timing.Duration-500) > 1e-9 {
+		t.Errorf("expected duration 500, got %f", timing.Duration)
+	}
+}
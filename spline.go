@@ -3,6 +3,7 @@ package osu
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -16,6 +17,13 @@ const (
 	SPLINE_CATMULL = 'C'
 
 	CURVE_THRESHOLD = 1.0
+
+	// number of points to generate between each pair of catmull control points
+	CATMULL_DETAIL = 50
+
+	// maximum distance between a perfect circle arc and the lines used to
+	// approximate it
+	ARC_THRESHOLD = 0.1
 )
 
 func ParseControlPoints(line string) (kind SplineKind, points []IntPoint, err error) {
@@ -23,12 +31,20 @@ func ParseControlPoints(line string) (kind SplineKind, points []IntPoint, err er
 
 	for i, s := range pointsStr {
 		if i == 0 {
+			if len(s) == 0 {
+				err = errors.New("missing spline kind")
+				return
+			}
 			kind = []rune(s)[0]
 			continue
 		}
 
 		var x, y int
 		pair := strings.Split(s, ":")
+		if len(pair) < 2 {
+			err = fmt.Errorf("len(point) = %d < 2", len(pair))
+			return
+		}
 
 		x, err = strconv.Atoi(pair[0])
 		if err != nil {
@@ -46,36 +62,298 @@ func ParseControlPoints(line string) (kind SplineKind, points []IntPoint, err er
 	return
 }
 
+func SerializeControlPoints(kind SplineKind, points []IntPoint) string {
+	parts := []string{string(kind)}
+	for _, p := range points {
+		parts = append(parts, fmt.Sprintf("%d:%d", p.x, p.y))
+	}
+	return strings.Join(parts, "|")
+}
+
 func SplineFrom(kind SplineKind, points []IntPoint, length float64) (spline []FloatPoint, err error) {
 	switch kind {
 	case SPLINE_LINEAR:
 		if len(points) < 2 {
 			err = errors.New("not enough points to create a linear spline")
 			return
-		} else if len(points) > 2 {
-			err = errors.New("trying to create linear spline with more than 2 points")
-			return
 		}
 
-		A := points[0].ToFloat()
-		B := points[1].ToFloat()
-
 		// since this is linear, and we can draw lines via the graphics library anyway,
-		// we don't need to calculate a million points
-		spline = append(spline, A)
+		// we don't need to calculate a million points; just the corners
+		for _, p := range points {
+			if len(spline) > 0 && spline[len(spline)-1] == p.ToFloat() {
+				continue
+			}
+			spline = append(spline, p.ToFloat())
+		}
 
-		// for the end point, we have to shorten the slider based on the pixel length
-		// given to us in the osu file
-		unit := B.Sub(A).Norm()
-		spline = append(spline, unit.ScalarMul(length))
+		// the end point is wherever we are after walking pixelLength along the
+		// path, which isn't necessarily the last point
+		spline = truncateSpline(spline, length)
 	case SPLINE_PERFECT:
+		// the game only draws a circle through exactly three points that aren't
+		// on the same line, and treats anything else as a bezier instead
+		if arc := perfectSpline(points, length); arc != nil {
+			spline = truncateSpline(arc, length)
+			return
+		}
+		return SplineFrom(SPLINE_BEZIER, points, length)
 	case SPLINE_BEZIER:
+		if len(points) < 2 {
+			err = errors.New("not enough points to create a bezier spline")
+			return
+		}
+
+		spline = truncateSpline(bezierSpline(points), length)
 	case SPLINE_CATMULL:
 		// deprecated, but it still appears in older maps
-		// so we'll just error it out for now and implement it later
-		err = fmt.Errorf("catmull hasn't been implemented yet")
+		if len(points) < 2 {
+			err = errors.New("not enough points to create a catmull spline")
+			return
+		}
+
+		spline = truncateSpline(catmullSpline(points), length)
 	default:
 		err = fmt.Errorf("unknown spline kind: %v", kind)
 	}
 	return
 }
+
+// perfectSpline approximates the arc of the circle passing through the three
+// given points, starting at the first one and continuing for length pixels in
+// the direction of the second. It returns nil if there isn't such a circle.
+func perfectSpline(points []IntPoint, length float64) (spline []FloatPoint) {
+	if len(points) != 3 {
+		return nil
+	}
+
+	a, b, c := points[0].ToFloat(), points[1].ToFloat(), points[2].ToFloat()
+
+	// (twice) the signed area of the triangle; zero if they're on a line
+	d := 2 * (a.x*(b.y-c.y) + b.x*(c.y-a.y) + c.x*(a.y-b.y))
+	if math.Abs(d) < 1e-3 {
+		return nil
+	}
+
+	aSq := a.x*a.x + a.y*a.y
+	bSq := b.x*b.x + b.y*b.y
+	cSq := c.x*c.x + c.y*c.y
+	centre := FloatPoint{
+		x: (aSq*(b.y-c.y) + bSq*(c.y-a.y) + cSq*(a.y-b.y)) / d,
+		y: (aSq*(c.x-b.x) + bSq*(a.x-c.x) + cSq*(b.x-a.x)) / d,
+	}
+
+	dA := a.Sub(centre)
+	dC := c.Sub(centre)
+	radius := dA.Magnitude()
+
+	thetaStart := math.Atan2(dA.y, dA.x)
+	thetaEnd := math.Atan2(dC.y, dC.x)
+	for thetaEnd < thetaStart {
+		thetaEnd += 2 * math.Pi
+	}
+
+	// go the other way around if b is on the other side of the line from a to c
+	direction := 1.0
+	thetaRange := thetaEnd - thetaStart
+	orthoAtoC := FloatPoint{c.y - a.y, a.x - c.x}
+	ba := b.Sub(a)
+	if orthoAtoC.x*ba.x+orthoAtoC.y*ba.y < 0 {
+		direction = -1
+		thetaRange = 2*math.Pi - thetaRange
+	}
+
+	// the length of the slider decides how far around the circle it goes, not
+	// the position of the last point
+	if length > 0 {
+		thetaRange = length / radius
+	}
+
+	// use enough points that no segment strays more than ARC_THRESHOLD from
+	// the real arc
+	nPoints := 2
+	if 2*radius > ARC_THRESHOLD {
+		step := 2 * math.Acos(1-ARC_THRESHOLD/radius)
+		nPoints = int(math.Max(2, math.Ceil(thetaRange/step)+1))
+	}
+
+	for i := 0; i < nPoints; i++ {
+		theta := thetaStart + direction*thetaRange*float64(i)/float64(nPoints-1)
+		offset := FloatPoint{math.Cos(theta), math.Sin(theta)}.ScalarMul(radius)
+		spline = append(spline, centre.Add(offset))
+	}
+	return
+}
+
+// catmullSpline approximates a Catmull-Rom spline passing through
+// every one of the points.
+func catmullSpline(points []IntPoint) (spline []FloatPoint) {
+	for i := 0; i < len(points)-1; i++ {
+		// the curve through each pair of points depends on the points on either
+		// side, so make some up at the ends
+		v2 := points[i].ToFloat()
+		v3 := points[i+1].ToFloat()
+		v1 := v2
+		if i > 0 {
+			v1 = points[i-1].ToFloat()
+		}
+		v4 := v3.ScalarMul(2).Sub(v2)
+		if i < len(points)-2 {
+			v4 = points[i+2].ToFloat()
+		}
+
+		for c := 0; c <= CATMULL_DETAIL; c++ {
+			p := catmullPoint(v1, v2, v3, v4, float64(c)/CATMULL_DETAIL)
+			if len(spline) > 0 && spline[len(spline)-1] == p {
+				continue
+			}
+			spline = append(spline, p)
+		}
+	}
+	return
+}
+
+func catmullPoint(v1, v2, v3, v4 FloatPoint, t float64) FloatPoint {
+	t2 := t * t
+	t3 := t * t2
+
+	component := func(a, b, c, d float64) float64 {
+		return 0.5 * (2*b + (-a+c)*t + (2*a-5*b+4*c-d)*t2 + (-a+3*b-3*c+d)*t3)
+	}
+	return FloatPoint{
+		x: component(v1.x, v2.x, v3.x, v4.x),
+		y: component(v1.y, v2.y, v3.y, v4.y),
+	}
+}
+
+// bezierSpline approximates a (possibly multi-segment) bezier curve. Segments
+// are separated by "red" anchor points, which are written as the same point
+// twice in a row.
+func bezierSpline(points []IntPoint) (spline []FloatPoint) {
+	start := 0
+	for i := range points {
+		if i < len(points)-1 && points[i] != points[i+1] {
+			continue
+		}
+
+		segment := make([]FloatPoint, i+1-start)
+		for j := range segment {
+			segment[j] = points[start+j].ToFloat()
+		}
+		start = i + 1
+
+		for j, p := range approximateBezier(segment) {
+			// the first point of a segment is the last point of the previous one
+			if j == 0 && len(spline) > 0 && spline[len(spline)-1] == p {
+				continue
+			}
+			spline = append(spline, p)
+		}
+	}
+	return
+}
+
+// approximateBezier turns a single bezier curve into a list of points by
+// subdividing it until every piece is within CURVE_THRESHOLD of a straight
+// line.
+func approximateBezier(points []FloatPoint) (output []FloatPoint) {
+	var approximate func(points []FloatPoint)
+	approximate = func(points []FloatPoint) {
+		left, right := subdivideBezier(points)
+		if !bezierIsFlatEnough(points) {
+			approximate(left)
+			approximate(right)
+			return
+		}
+
+		// flat enough; smooth out the control points of the two halves, and
+		// use them as the approximation
+		n := len(points)
+		joined := append(left, right[1:]...)
+		output = append(output, points[0])
+		for i := 1; i < n-1; i++ {
+			index := 2 * i
+			p := joined[index-1].Add(joined[index].ScalarMul(2)).Add(joined[index+1]).ScalarMul(0.25)
+			output = append(output, p)
+		}
+	}
+
+	approximate(points)
+	return append(output, points[len(points)-1])
+}
+
+func bezierIsFlatEnough(points []FloatPoint) bool {
+	for i := 1; i < len(points)-1; i++ {
+		p := points[i-1].Sub(points[i].ScalarMul(2)).Add(points[i+1])
+		if p.Magnitude() > CURVE_THRESHOLD*2 {
+			return false
+		}
+	}
+	return true
+}
+
+// subdivideBezier splits a bezier curve in half using de Casteljau's algorithm.
+func subdivideBezier(points []FloatPoint) (left, right []FloatPoint) {
+	n := len(points)
+	midpoints := make([]FloatPoint, n)
+	copy(midpoints, points)
+
+	left = make([]FloatPoint, n)
+	right = make([]FloatPoint, n)
+	for i := 0; i < n; i++ {
+		left[i] = midpoints[0]
+		right[n-i-1] = midpoints[n-i-1]
+
+		for j := 0; j < n-i-1; j++ {
+			midpoints[j] = midpoints[j].Add(midpoints[j+1]).ScalarMul(0.5)
+		}
+	}
+	return
+}
+
+// truncateSpline cuts the spline off once it reaches length, or extends the
+// last segment in a straight line if it isn't long enough, which is how the
+// game makes the slider match its pixelLength.
+func truncateSpline(spline []FloatPoint, length float64) []FloatPoint {
+	if len(spline) < 2 || length <= 0 {
+		return spline
+	}
+
+	var traveled float64
+	for i := 1; i < len(spline); i++ {
+		segment := spline[i].Sub(spline[i-1])
+		segmentLength := segment.Magnitude()
+
+		if traveled+segmentLength >= length {
+			end := spline[i-1].Add(segment.ScalarMul((length - traveled) / segmentLength))
+			return append(spline[:i:i], end)
+		}
+		traveled += segmentLength
+	}
+
+	// too short; find the last segment with a direction and extend it
+	last := len(spline) - 1
+	for i := last; i > 0; i-- {
+		segment := spline[i].Sub(spline[i-1])
+		if segment.Magnitude() == 0 {
+			continue
+		}
+
+		end := spline[last].Add(segment.Norm().ScalarMul(length - traveled))
+		return append(spline[:last:last], end)
+	}
+	return spline
+}
+
+// splineLengths returns the distance along the spline to each of its points.
+func splineLengths(spline []FloatPoint) []float64 {
+	if len(spline) == 0 {
+		return nil
+	}
+
+	lengths := make([]float64, len(spline))
+	for i := 1; i < len(spline); i++ {
+		lengths[i] = lengths[i-1] + spline[i].Sub(spline[i-1]).Magnitude()
+	}
+	return lengths
+}
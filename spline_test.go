@@ -0,0 +1,151 @@
+package osu
+
+import (
+	"math"
+	"testing"
+)
+
+func splineLength(spline []FloatPoint) (length float64) {
+	for i := 1; i < len(spline); i++ {
+		length += spline[i].Sub(spline[i-1]).Magnitude()
+	}
+	return
+}
+
+func approxPoint(p1, p2 FloatPoint) bool {
+	return p1.Sub(p2).Magnitude() < 0.01
+}
+
+func TestBezierSpline(t *testing.T) {
+	// a degenerate bezier is just a line, which should get cut short
+	spline, err := SplineFrom(SPLINE_BEZIER, []IntPoint{{0, 0}, {100, 0}}, 50)
+	if err != nil {
+		t.Fatalf("failed to create spline: %v", err)
+	}
+	if end := spline[len(spline)-1]; !approxPoint(end, FloatPoint{50, 0}) {
+		t.Errorf("expected line to end at (50, 0), got %v", end)
+	}
+
+	// or extended
+	spline, _ = SplineFrom(SPLINE_BEZIER, []IntPoint{{0, 0}, {100, 0}}, 150)
+	if end := spline[len(spline)-1]; !approxPoint(end, FloatPoint{150, 0}) {
+		t.Errorf("expected line to end at (150, 0), got %v", end)
+	}
+
+	// a curve should follow its control points and end up the right length
+	spline, _ = SplineFrom(SPLINE_BEZIER, []IntPoint{{0, 0}, {100, 100}, {200, 0}}, 200)
+	if length := splineLength(spline); math.Abs(length-200) > 0.01 {
+		t.Errorf("expected curve of length 200, got %f", length)
+	}
+	for _, p := range spline {
+		if p.y < 0 || p.y > 50 {
+			t.Errorf("point %v is outside the curve", p)
+		}
+	}
+
+	// red anchors split the curve into separate segments, which meet at a corner
+	spline, _ = SplineFrom(SPLINE_BEZIER, []IntPoint{{0, 0}, {100, 0}, {100, 0}, {100, 100}}, 200)
+	found := false
+	for _, p := range spline {
+		if approxPoint(p, FloatPoint{100, 0}) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the spline to pass through the red anchor, got %v", spline)
+	}
+	if end := spline[len(spline)-1]; !approxPoint(end, FloatPoint{100, 100}) {
+		t.Errorf("expected spline to end at (100, 100), got %v", end)
+	}
+}
+
+func TestPerfectSpline(t *testing.T) {
+	// half of a circle of radius 50 centred on (50, 0)
+	length := math.Pi * 50
+	spline, err := SplineFrom(SPLINE_PERFECT, []IntPoint{{0, 0}, {50, 50}, {100, 0}}, length)
+	if err != nil {
+		t.Fatalf("failed to create spline: %v", err)
+	}
+	// the arc is made of straight lines, so it's a tiny bit short and the end
+	// gets extended to make up for it
+	if end := spline[len(spline)-1]; end.Sub(FloatPoint{100, 0}).Magnitude() > 2*ARC_THRESHOLD {
+		t.Errorf("expected arc to end at (100, 0), got %v", end)
+	}
+	if l := splineLength(spline); math.Abs(l-length) > 0.01 {
+		t.Errorf("expected arc of length %f, got %f", length, l)
+	}
+	for _, p := range spline {
+		if math.Abs(p.Sub(FloatPoint{50, 0}).Magnitude()-50) > 2*ARC_THRESHOLD {
+			t.Errorf("point %v isn't on the circle", p)
+		}
+		if p.y < -2*ARC_THRESHOLD {
+			t.Errorf("arc went the wrong way around: %v", p)
+		}
+	}
+
+	// the slider's length decides where it ends, even past the last point
+	spline, _ = SplineFrom(SPLINE_PERFECT, []IntPoint{{0, 0}, {50, 50}, {100, 0}}, length*1.5)
+	if end := spline[len(spline)-1]; end.Sub(FloatPoint{50, -50}).Magnitude() > 2*ARC_THRESHOLD {
+		t.Errorf("expected arc to end at (50, -50), got %v", end)
+	}
+
+	// points on a line fall back to being a bezier
+	spline, _ = SplineFrom(SPLINE_PERFECT, []IntPoint{{0, 0}, {50, 0}, {100, 0}}, 100)
+	if end := spline[len(spline)-1]; !approxPoint(end, FloatPoint{100, 0}) {
+		t.Errorf("expected line to end at (100, 0), got %v", end)
+	}
+}
+
+func TestCatmullSpline(t *testing.T) {
+	points := []IntPoint{{0, 0}, {100, 0}, {200, 100}}
+	spline, err := SplineFrom(SPLINE_CATMULL, points, 0)
+	if err != nil {
+		t.Fatalf("failed to create spline: %v", err)
+	}
+
+	// unlike a bezier, the curve passes through every point
+	for _, point := range points {
+		found := false
+		for _, p := range spline {
+			if approxPoint(p, point.ToFloat()) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected spline to pass through %v", point)
+		}
+	}
+
+	spline, _ = SplineFrom(SPLINE_CATMULL, points, 150)
+	if length := splineLength(spline); math.Abs(length-150) > 0.01 {
+		t.Errorf("expected curve of length 150, got %f", length)
+	}
+}
+
+func TestLinearSpline(t *testing.T) {
+	// the end point is an absolute position, not an offset from the head
+	spline, err := SplineFrom(SPLINE_LINEAR, []IntPoint{{100, 100}, {200, 100}}, 50)
+	if err != nil {
+		t.Fatalf("failed to create spline: %v", err)
+	}
+	if end := spline[len(spline)-1]; !approxPoint(end, FloatPoint{150, 100}) {
+		t.Errorf("expected line to end at (150, 100), got %v", end)
+	}
+
+	// any number of points makes a polyline
+	points := []IntPoint{{0, 0}, {100, 0}, {100, 100}, {0, 100}}
+	spline, err = SplineFrom(SPLINE_LINEAR, points, 250)
+	if err != nil {
+		t.Fatalf("failed to create spline: %v", err)
+	}
+	expected := []FloatPoint{{0, 0}, {100, 0}, {100, 100}, {50, 100}}
+	if len(spline) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, spline)
+	}
+	for i := range expected {
+		if !approxPoint(spline[i], expected[i]) {
+			t.Errorf("expected %v, got %v", expected, spline)
+			break
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package osu
+
+import (
+	"math"
+	"sort"
+)
+
+type TaikoHitType = int
+
+const (
+	TAIKO_DON      = 0
+	TAIKO_KAT      = 1
+	TAIKO_DRUMROLL = 2
+	TAIKO_SWELL    = 3
+)
+
+// how much faster objects scroll in osu!taiko than sliders move in osu!
+const TAIKO_VELOCITY_MULTIPLIER = 1.4
+
+// TaikoObject is a hit object as it's played in osu!taiko.
+type TaikoObject struct {
+	Type TaikoHitType
+
+	// Strong objects are the big ones, which are worth more when hit with
+	// both keys
+	Strong bool
+
+	StartTime float64
+	EndTime   float64
+
+	// RequiredHits is how many times a swell has to be hit
+	RequiredHits int
+}
+
+// IsHit returns whether the object is a don or a kat.
+func (obj TaikoObject) IsHit() bool {
+	return obj.Type == TAIKO_DON || obj.Type == TAIKO_KAT
+}
+
+// TaikoHitTypeOf classifies a hit by its hitsound: whistles and claps are
+// kats, and anything else is a don. Finishes make the hit strong.
+func TaikoHitTypeOf(hitsound Hitsound) (hitType TaikoHitType, strong bool) {
+	hitType = TAIKO_DON
+	if hitsound&(HITSOUND_WHISTLE|HITSOUND_CLAP) != 0 {
+		hitType = TAIKO_KAT
+	}
+	return hitType, hitsound&HITSOUND_FINISH != 0
+}
+
+// TaikoObjects converts the hit objects into osu!taiko objects, in order of
+// start time. Maps made for other modes are converted the same way as in the
+// game: short sliders become a series of hits, and longer ones drumrolls.
+func (m *Beatmap) TaikoObjects() []TaikoObject {
+	var objects []TaikoObject
+	for _, hitObject := range m.HitObjects {
+		startTime := float64((*hitObject).GetStartTime().Milliseconds())
+
+		switch o := (*hitObject).(type) {
+		case ObjSlider:
+			duration, tickSpacing, toHits := m.taikoSliderConversion(o)
+			if !toHits {
+				_, strong := TaikoHitTypeOf(o.additions)
+				objects = append(objects, TaikoObject{
+					Type:      TAIKO_DRUMROLL,
+					Strong:    strong,
+					StartTime: startTime,
+					EndTime:   startTime + float64(duration),
+				})
+				continue
+			}
+
+			// each hit takes its hitsound from the next edge of the slider
+			hitsounds := o.EdgeHitsounds()
+			i := 0
+			for t := startTime; t <= startTime+float64(duration)+tickSpacing/8; t += tickSpacing {
+				hitType, strong := TaikoHitTypeOf(hitsounds[i])
+				objects = append(objects, TaikoObject{Type: hitType, Strong: strong, StartTime: t, EndTime: t})
+				i = (i + 1) % len(hitsounds)
+				if tickSpacing == 0 {
+					break
+				}
+			}
+		case ObjSpinner:
+			endTime := float64(o.endTime.Milliseconds())
+			hitMultiplier := DifficultyRange(m.OverallDifficulty, 3, 5, 7.5) * 1.65
+			objects = append(objects, TaikoObject{
+				Type:         TAIKO_SWELL,
+				StartTime:    startTime,
+				EndTime:      endTime,
+				RequiredHits: int(math.Max(1, (endTime-startTime)/1000*hitMultiplier)),
+			})
+		default:
+			hitType, strong := TaikoHitTypeOf((*hitObject).GetHitsound())
+			objects = append(objects, TaikoObject{Type: hitType, Strong: strong, StartTime: startTime, EndTime: startTime})
+		}
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool { return objects[i].StartTime < objects[j].StartTime })
+	return objects
+}
+
+// taikoSliderConversion works out how long a slider lasts as a drumroll, and
+// whether it should be split into hits instead.
+func (m *Beatmap) taikoSliderConversion(obj ObjSlider) (duration int, tickSpacing float64, toHits bool) {
+	spans := float64(obj.repeatCount)
+	distance := obj.pixelLength * spans * TAIKO_VELOCITY_MULTIPLIER
+
+	beatLength, speedAdjustedBeatLength := 1000.0, 1000.0
+	if tp := m.TimingPointAt(obj.startTime.Milliseconds()); tp != nil {
+		beatLength = tp.GetBeatLength()
+		speedAdjustedBeatLength = beatLength
+		if inherited, ok := tp.(InheritedTimingPoint); ok {
+			speedAdjustedBeatLength /= math.Max(0.1, math.Min(10, inherited.SvMultiplier))
+		}
+	}
+
+	sliderScoringPointDistance := BASE_SCORING_DISTANCE * m.SliderMultiplier * TAIKO_VELOCITY_MULTIPLIER / m.SliderTickRate
+	taikoVelocity := sliderScoringPointDistance * m.SliderTickRate
+	duration = int(distance / taikoVelocity * speedAdjustedBeatLength)
+
+	// sliders in maps made for taiko are always drumrolls
+	if m.Mode == MODE_TAIKO {
+		return duration, 0, false
+	}
+
+	osuVelocity := taikoVelocity * 1000 / speedAdjustedBeatLength
+
+	// older maps used the slider velocity for this too
+	if m.Version < 8 {
+		beatLength = speedAdjustedBeatLength
+	}
+
+	tickSpacing = math.Min(beatLength/m.SliderTickRate, float64(duration)/spans)
+	return duration, tickSpacing, tickSpacing > 0 && distance/osuVelocity*1000 < 2*beatLength
+}
@@ -0,0 +1,79 @@
+package osu
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTaikoHitTypes(t *testing.T) {
+	tests := []struct {
+		hitsound Hitsound
+		hitType  TaikoHitType
+		strong   bool
+	}{
+		{0, TAIKO_DON, false},
+		{HITSOUND_NORMAL, TAIKO_DON, false},
+		{HITSOUND_WHISTLE, TAIKO_KAT, false},
+		{HITSOUND_CLAP, TAIKO_KAT, false},
+		{HITSOUND_FINISH, TAIKO_DON, true},
+		{HITSOUND_CLAP | HITSOUND_FINISH, TAIKO_KAT, true},
+	}
+	for _, test := range tests {
+		if hitType, strong := TaikoHitTypeOf(test.hitsound); hitType != test.hitType || strong != test.strong {
+			t.Errorf("hitsound %d: expected %d/%v, got %d/%v", test.hitsound, test.hitType, test.strong, hitType, strong)
+		}
+	}
+}
+
+const taikoConvertMap = `osu file format v14
+
+[General]
+Mode: %d
+
+[Difficulty]
+OverallDifficulty:5
+SliderMultiplier:1
+SliderTickRate:1
+
+[TimingPoints]
+0,500,4,2,0,100,1,0
+
+[HitObjects]
+256,192,0,1,2,0:0:0:0:
+0,192,500,2,0,L|100:192,2,50,4|0|8
+0,192,2000,2,0,L|500:192,1,500
+256,192,4000,12,0,6000,0:0:0:0:
+`
+
+func TestTaikoObjects(t *testing.T) {
+	// converted from osu!standard, the short slider becomes hits
+	m, err := ParseBeatmap(strings.NewReader(strings.Replace(taikoConvertMap, "%d", "0", 1)))
+	if err != nil {
+		t.Fatalf("failed to parse map: %v", err)
+	}
+
+	expected := []TaikoObject{
+		{Type: TAIKO_KAT, StartTime: 0, EndTime: 0},
+		{Type: TAIKO_DON, Strong: true, StartTime: 500, EndTime: 500},
+		{Type: TAIKO_DON, StartTime: 750, EndTime: 750},
+		{Type: TAIKO_KAT, StartTime: 1000, EndTime: 1000},
+		{Type: TAIKO_DRUMROLL, StartTime: 2000, EndTime: 4500},
+		{Type: TAIKO_SWELL, StartTime: 4000, EndTime: 6000, RequiredHits: 16},
+	}
+	objects := m.TaikoObjects()
+	if len(objects) != len(expected) {
+		t.Fatalf("expected %d objects, got %+v", len(expected), objects)
+	}
+	for i := range objects {
+		if objects[i] != expected[i] {
+			t.Errorf("object %d: expected %+v, got %+v", i, expected[i], objects[i])
+		}
+	}
+
+	// in a taiko map, sliders are always drumrolls
+	m, _ = ParseBeatmap(strings.NewReader(strings.Replace(taikoConvertMap, "%d", "1", 1)))
+	objects = m.TaikoObjects()
+	if len(objects) != 4 || objects[1].Type != TAIKO_DRUMROLL || objects[1].EndTime != 1000 {
+		t.Errorf("expected slider to be a drumroll, got %+v", objects)
+	}
+}
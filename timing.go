@@ -1,9 +1,12 @@
 package osu
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"sort"
+	"strconv"
+	"strings"
 )
 
 var (
@@ -24,6 +27,35 @@ func (t TimestampAbsolute) Milliseconds() int {
 	return int(t)
 }
 
+// TimestampFractional is an absolute timestamp that isn't a whole number of
+// milliseconds, which some older maps have for their timing points.
+type TimestampFractional float64
+
+func (t TimestampFractional) Milliseconds() int {
+	return int(math.Round(float64(t)))
+}
+
+// parseTimestamp parses an absolute timestamp in milliseconds, which some
+// older maps write with a fractional part.
+func parseTimestamp(s string) (Timestamp, error) {
+	val, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	if val != math.Trunc(val) {
+		return TimestampFractional(val), nil
+	}
+	return TimestampAbsolute(val), nil
+}
+
+// formatTimestamp writes out a timestamp, keeping the fraction if it has one.
+func formatTimestamp(t Timestamp) string {
+	if fractional, ok := t.(TimestampFractional); ok {
+		return strconv.FormatFloat(float64(fractional), 'f', -1, 64)
+	}
+	return strconv.Itoa(t.Milliseconds())
+}
+
 type snapping struct {
 	num   int
 	denom int
@@ -58,7 +90,7 @@ func (t TimestampAbsolute) IntoRelative(to Timestamp, bpm float64, meter int) (*
 	measureStart := float64(base) + float64(measures)*msPerMeasure
 	offset := float64(cur) - measureStart
 
-	snapTimes := make([]snapping, len(SNAPPINGS)*16)
+	snapTimes := make([]snapping, 0, len(SNAPPINGS)*16)
 	for _, denom := range SNAPPINGS {
 		for i := 0; i < denom; i++ {
 			var snapAt float64
@@ -117,6 +149,12 @@ func (t TimestampRelative) Milliseconds() int {
 	return int(float64(base) + measureOffset + remainingOffset)
 }
 
+// Effect flags that can be set on a timing point
+const (
+	EFFECT_KIAI               = 1
+	EFFECT_OMIT_FIRST_BARLINE = 8
+)
+
 type TimingPoint interface {
 	// Get the timestamp
 	GetTimestamp() Timestamp
@@ -124,14 +162,126 @@ type TimingPoint interface {
 	// Get the BPM of the nearest uninherited timing section to which this belongs
 	GetBPM() float64
 
+	// Get the beat length, in milliseconds, of the nearest uninherited timing
+	// section to which this belongs
+	GetBeatLength() float64
+
 	// Get the meter of the nearest uninherited timing section to which this belongs
 	GetMeter() int
+
+	Serialize() (string, error)
 }
 
 type UninheritedTimingPoint struct {
 	BPM   float64
 	Meter int
 	Time  Timestamp
+
+	// BeatLength is the length of a beat in milliseconds exactly as the file
+	// stores it, so it can be written back without rounding errors. It's
+	// ignored if it doesn't match BPM.
+	BeatLength float64
+
+	SampleSet   SampleSet
+	SampleIndex int
+	Volume      int
+	Effects     int
+}
+
+// ParseTimingPoint parses a single line of the [TimingPoints] section. parent
+// should be the most recent uninherited timing point, and is used as the
+// Parent of the result if the line turns out to be an inherited point.
+func ParseTimingPoint(line string, parent TimingPoint) (TimingPoint, error) {
+	var err error
+
+	parts := strings.Split(line, ",")
+	if len(parts) < 2 {
+		return nil, errors.New("len(parts) < 2")
+	}
+
+	time, err := parseTimestamp(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	beatLength, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return nil, err
+	}
+
+	// the remaining fields were added in later versions of the format, so
+	// they're all optional
+	meter := 4
+	if len(parts) > 2 {
+		if meter, err = strconv.Atoi(parts[2]); err != nil {
+			return nil, err
+		}
+	}
+
+	var sampleSet, sampleIndex int
+	if len(parts) > 3 {
+		if sampleSet, err = strconv.Atoi(parts[3]); err != nil {
+			return nil, err
+		}
+	}
+	if len(parts) > 4 {
+		if sampleIndex, err = strconv.Atoi(parts[4]); err != nil {
+			return nil, err
+		}
+	}
+
+	volume := 100
+	if len(parts) > 5 {
+		if volume, err = strconv.Atoi(parts[5]); err != nil {
+			return nil, err
+		}
+	}
+
+	// before the uninherited field existed, inherited points were marked only
+	// by having a negative beat length
+	uninherited := beatLength >= 0
+	if len(parts) > 6 {
+		val, err := strconv.Atoi(parts[6])
+		if err != nil {
+			return nil, err
+		}
+		uninherited = val > 0
+	}
+
+	var effects int
+	if len(parts) > 7 {
+		if effects, err = strconv.Atoi(parts[7]); err != nil {
+			return nil, err
+		}
+	}
+
+	if uninherited {
+		if beatLength <= 0 {
+			return nil, fmt.Errorf("invalid beat length for uninherited timing point: %v", beatLength)
+		}
+
+		return UninheritedTimingPoint{
+			BPM:         60000.0 / beatLength,
+			Meter:       meter,
+			Time:        time,
+			BeatLength:  beatLength,
+			SampleSet:   sampleSet,
+			SampleIndex: sampleIndex,
+			Volume:      volume,
+			Effects:     effects,
+		}, nil
+	}
+
+	return InheritedTimingPoint{
+		Parent:       parent,
+		Time:         time,
+		SvMultiplier: svMultiplierOf(beatLength),
+		BeatLength:   beatLength,
+		SampleSet:    sampleSet,
+		SampleIndex:  sampleIndex,
+		Volume:       volume,
+		Effects:      effects,
+	}, nil
 }
 
 func (tp UninheritedTimingPoint) GetTimestamp() Timestamp {
@@ -142,14 +292,44 @@ func (tp UninheritedTimingPoint) GetBPM() float64 {
 	return tp.BPM
 }
 
+func (tp UninheritedTimingPoint) GetBeatLength() float64 {
+	if tp.BeatLength != 0 && 60000.0/tp.BeatLength == tp.BPM {
+		return tp.BeatLength
+	}
+	return 60000.0 / tp.BPM
+}
+
 func (tp UninheritedTimingPoint) GetMeter() int {
 	return tp.Meter
 }
 
+func (tp UninheritedTimingPoint) Serialize() (string, error) {
+	return fmt.Sprintf("%s,%s,%d,%d,%d,%d,%d,%d",
+		formatTimestamp(tp.Time),
+		strconv.FormatFloat(tp.GetBeatLength(), 'f', -1, 64),
+		tp.Meter,
+		tp.SampleSet,
+		tp.SampleIndex,
+		tp.Volume,
+		1,
+		tp.Effects,
+	), nil
+}
+
 type InheritedTimingPoint struct {
 	Parent       TimingPoint
 	Time         Timestamp
 	SvMultiplier float64
+
+	// BeatLength is the slider velocity exactly as the file stores it, as a
+	// negative inverse percentage, so -50 is double speed. It's ignored if it
+	// doesn't match SvMultiplier.
+	BeatLength float64
+
+	SampleSet   SampleSet
+	SampleIndex int
+	Volume      int
+	Effects     int
 }
 
 func (tp InheritedTimingPoint) GetTimestamp() Timestamp {
@@ -160,6 +340,48 @@ func (tp InheritedTimingPoint) GetBPM() float64 {
 	return tp.Parent.GetBPM()
 }
 
+func (tp InheritedTimingPoint) GetBeatLength() float64 {
+	return tp.Parent.GetBeatLength()
+}
+
+// svMultiplierOf converts the beat length of an inherited point to a slider
+// velocity multiplier. Points without a negative beat length don't change the
+// slider velocity.
+func svMultiplierOf(beatLength float64) float64 {
+	if beatLength < 0 {
+		return -100.0 / beatLength
+	}
+	return 1
+}
+
 func (tp InheritedTimingPoint) GetMeter() int {
 	return tp.Parent.GetMeter()
 }
+
+func (tp InheritedTimingPoint) Serialize() (string, error) {
+	beatLength := tp.BeatLength
+	if beatLength == 0 || svMultiplierOf(beatLength) != tp.SvMultiplier {
+		if tp.SvMultiplier <= 0 {
+			return "", fmt.Errorf("invalid slider velocity multiplier: %v", tp.SvMultiplier)
+		}
+		beatLength = -100.0 / tp.SvMultiplier
+	}
+
+	// the meter of an inherited point is ignored, but the game writes out the
+	// parent's anyway
+	meter := 4
+	if tp.Parent != nil {
+		meter = tp.Parent.GetMeter()
+	}
+
+	return fmt.Sprintf("%s,%s,%d,%d,%d,%d,%d,%d",
+		formatTimestamp(tp.Time),
+		strconv.FormatFloat(beatLength, 'f', -1, 64),
+		meter,
+		tp.SampleSet,
+		tp.SampleIndex,
+		tp.Volume,
+		0,
+		tp.Effects,
+	), nil
+}
@@ -193,3 +193,65 @@ func TestTiming(t *testing.T) {
 		t.Run(fmt.Sprintf("test%d", c), timingSubtest(c, tcase))
 	}
 }
+
+func TestParseTimingPoint(t *testing.T) {
+	tp, err := ParseTimingPoint("730,480,4,2,2,20,1,0", nil)
+	if err != nil {
+		t.Fatalf("failed to parse uninherited point: %v", err)
+	}
+	uninherited, ok := tp.(UninheritedTimingPoint)
+	if !ok {
+		t.Fatalf("expected UninheritedTimingPoint, got %T", tp)
+	}
+	if uninherited.BPM != 125 || uninherited.Time.Milliseconds() != 730 || uninherited.Volume != 20 {
+		t.Errorf("wrong uninherited point: %+v", uninherited)
+	}
+
+	tp, err = ParseTimingPoint("1450,-50,4,2,1,20,0,1", uninherited)
+	if err != nil {
+		t.Fatalf("failed to parse inherited point: %v", err)
+	}
+	inherited, ok := tp.(InheritedTimingPoint)
+	if !ok {
+		t.Fatalf("expected InheritedTimingPoint, got %T", tp)
+	}
+	if inherited.SvMultiplier != 2 || inherited.GetBPM() != 125 || inherited.Effects != EFFECT_KIAI {
+		t.Errorf("wrong inherited point: %+v", inherited)
+	}
+
+	line, err := inherited.Serialize()
+	if err != nil || line != "1450,-50,4,2,1,20,0,1" {
+		t.Errorf("wrong serialization: '%s' (%v)", line, err)
+	}
+
+	// old maps only have the offset and beat length
+	tp, err = ParseTimingPoint("8344,463.166507120507", nil)
+	if err != nil {
+		t.Fatalf("failed to parse short timing point: %v", err)
+	}
+	if tp.GetMeter() != 4 {
+		t.Errorf("expected default meter, got %d", tp.GetMeter())
+	}
+
+	// fractional offsets and beat lengths are kept exactly
+	tp, err = ParseTimingPoint("1234.5,333.333333333333,4,1,0,100,1,0", nil)
+	if err != nil {
+		t.Fatalf("failed to parse fractional timing point: %v", err)
+	}
+	if tp.GetTimestamp() != TimestampFractional(1234.5) || tp.GetTimestamp().Milliseconds() != 1235 || tp.GetBeatLength() != 333.333333333333 {
+		t.Errorf("wrong fractional timing point: %+v", tp)
+	}
+	if line, _ := tp.Serialize(); line != "1234.5,333.333333333333,4,1,0,100,1,0" {
+		t.Errorf("wrong serialization: '%s'", line)
+	}
+
+	// changing the BPM or slider velocity replaces the beat length
+	uninherited.BPM = 240
+	inherited.SvMultiplier = 0.5
+	if line, _ := uninherited.Serialize(); line != "730,250,4,2,2,20,1,0" {
+		t.Errorf("wrong serialization after changing BPM: '%s'", line)
+	}
+	if line, _ := inherited.Serialize(); line != "1450,-200,4,2,1,20,0,1" {
+		t.Errorf("wrong serialization after changing slider velocity: '%s'", line)
+	}
+}